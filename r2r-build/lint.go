@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/radareorg/r2r-go/regression"
+)
+
+// lint checks the text databases found in args, which may start with
+// --wdir <dir> to look up the fixtures in dir, and exits with 1 when there
+// is any problem.
+func lint(args []string) {
+	root := "."
+	if len(args) > 1 && args[0] == "--wdir" {
+		root = args[1]
+		args = args[2:]
+	}
+	if len(args) < 1 {
+		usage()
+	}
+	databases, err := regression.CollectDatabases(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err.Error())
+		os.Exit(1)
+	}
+	problems := 0
+	linted := 0
+	for _, database := range databases {
+		if strings.HasSuffix(database, ".json") {
+			continue
+		}
+		diagnostics, err := regression.Lint(database, root)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err.Error())
+			os.Exit(1)
+		}
+		for _, diag := range diagnostics {
+			fmt.Println(diag)
+		}
+		problems += len(diagnostics)
+		linted++
+	}
+	fmt.Println(problems, "problems found in", linted, "databases")
+	if problems > 0 {
+		os.Exit(1)
+	}
+}
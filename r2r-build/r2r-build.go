@@ -31,10 +31,25 @@ import (
 	"os"
 )
 
+func usage() {
+	fmt.Println(os.Args[0], "<path/regression/test> <file.json>")
+	fmt.Println(os.Args[0], "<path/regression/db> <output/directory>")
+	fmt.Println(os.Args[0], "--export <file.json> <path/regression/test>")
+	fmt.Println(os.Args[0], "--lint [--wdir <dir>] <path/regression/test|directory>...")
+	os.Exit(1)
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "--lint" {
+		lint(os.Args[2:])
+		return
+	}
+	if len(os.Args) == 4 && os.Args[1] == "--export" {
+		export(os.Args[2], os.Args[3])
+		return
+	}
 	if len(os.Args) != 3 {
-		fmt.Println(os.Args[0], "<path/regression/test> <file.json>")
-		os.Exit(1)
+		usage()
 	}
 	filepath := os.Args[1]
 	outputpath := os.Args[2]
@@ -44,5 +59,9 @@ func main() {
 	}
 	//fmt.Println("TESTS: ", filepath)
 	//fmt.Println("OUTPUT:", outputpath)
+	if info, err := os.Stat(filepath); err == nil && info.IsDir() {
+		buildTree(filepath, outputpath)
+		return
+	}
 	build(filepath, outputpath)
 }
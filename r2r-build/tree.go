@@ -0,0 +1,223 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/radareorg/r2r-go/regression"
+)
+
+// manifestVersion has to be increased whenever the parser changes the way it
+// converts the databases, or the generated databases are named differently,
+// so that all of them are rebuilt.
+const manifestVersion = 3
+
+const manifestFile = "manifest.json"
+
+type manifestEntry struct {
+	Source string `json:"source"`
+	Hash   string `json:"sha256"`
+	Type   string `json:"type"`
+	Tests  int    `json:"tests"`
+}
+
+// buildManifest records the source of each generated database, which is
+// rebuilt only when the content of the source changes.
+type buildManifest struct {
+	Version int                      `json:"version"`
+	Files   map[string]manifestEntry `json:"files"`
+}
+
+func loadManifest(outdir string) buildManifest {
+	manifest := buildManifest{manifestVersion, make(map[string]manifestEntry)}
+	raw, err := ioutil.ReadFile(filepath.Join(outdir, manifestFile))
+	if err != nil {
+		return manifest
+	}
+	var previous buildManifest
+	if err := json.Unmarshal(raw, &previous); err != nil || previous.Version != manifestVersion || previous.Files == nil {
+		return manifest
+	}
+	return previous
+}
+
+func writeJSON(fpath string, value interface{}) error {
+	bytes, err := json.MarshalIndent(value, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fpath, bytes, 0644)
+}
+
+type treeJob struct {
+	source  string // path relative to the input directory
+	output  string // name of the generated database
+	content []byte
+	hash    string
+	entry   manifestEntry
+	diags   []regression.Diagnostic
+	err     error
+}
+
+// convert parses the source of the job and writes its JSON database.
+func (job *treeJob) convert(indir, outdir string) {
+	regr, err := regression.Parse(bytes.NewReader(job.content), filepath.Join(indir, job.source))
+	if err != nil {
+		job.err = err
+		return
+	}
+	job.diags = regr.Diagnostics
+	job.entry = manifestEntry{filepath.ToSlash(job.source), job.hash, regr.Type, len(regr.Tests)}
+	job.err = writeJSON(filepath.Join(outdir, job.output), regr)
+}
+
+// buildTree converts all the text databases found in indir, in parallel, to
+// the JSON databases of outdir; the ones whose source did not change since
+// the last build are kept. The manifest and the index of outdir are updated.
+func buildTree(indir string, outdir string) {
+	sources, err := regression.CollectDatabases([]string{indir})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err.Error())
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(outdir, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err.Error())
+		os.Exit(1)
+	}
+	previous := loadManifest(outdir)
+	manifest := buildManifest{manifestVersion, make(map[string]manifestEntry)}
+
+	var jobs []*treeJob
+	var pending []*treeJob
+	sourceOf := make(map[string]string)
+	for _, fpath := range sources {
+		if strings.HasSuffix(fpath, ".json") {
+			continue
+		}
+		source, err := filepath.Rel(indir, fpath)
+		if err != nil {
+			source = fpath
+		}
+		// the directories are kept in the name, since databases with the
+		// same name are found in different directories (db/anal/x86 and
+		// db/asm/x86).
+		output := strings.Replace(filepath.ToSlash(source), "/", "_", -1) + ".json"
+		if other, ok := sourceOf[output]; ok {
+			fmt.Fprintln(os.Stderr, "Error:", source, "and", other, "would both be written to", output)
+			os.Exit(1)
+		}
+		sourceOf[output] = source
+		content, err := ioutil.ReadFile(fpath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err.Error())
+			os.Exit(1)
+		}
+		sum := sha256.Sum256(content)
+		job := &treeJob{source: source, output: output, content: content, hash: hex.EncodeToString(sum[:])}
+		jobs = append(jobs, job)
+		entry, ok := previous.Files[output]
+		if ok && entry.Hash == job.hash && entry.Source == filepath.ToSlash(source) && exists(filepath.Join(outdir, output)) {
+			job.entry = entry
+			continue
+		}
+		pending = append(pending, job)
+	}
+
+	queue := make(chan *treeJob)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				job.convert(indir, outdir)
+			}
+		}()
+	}
+	for _, job := range pending {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	failed := false
+	for _, job := range pending {
+		for _, diag := range job.diags {
+			fmt.Println(diag)
+		}
+		if job.err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", job.source+":", job.err.Error())
+			failed = true
+			continue
+		}
+		fmt.Println("Built:", job.output, "("+fmt.Sprint(job.entry.Tests), "tests)")
+	}
+
+	var index regression.R2Index
+	index.Databases = make([]regression.R2IndexEntry, 0, len(jobs))
+	for _, job := range jobs {
+		if job.err != nil {
+			continue
+		}
+		manifest.Files[job.output] = job.entry
+		index.Databases = append(index.Databases, regression.R2IndexEntry{File: job.output, Source: job.entry.Source, Type: job.entry.Type, Tests: job.entry.Tests})
+	}
+	sort.Slice(index.Databases, func(i, j int) bool {
+		return index.Databases[i].Source < index.Databases[j].Source
+	})
+	removed := 0
+	for output := range previous.Files {
+		if _, ok := sourceOf[output]; !ok {
+			os.Remove(filepath.Join(outdir, output))
+			removed++
+		}
+	}
+	if err := writeJSON(filepath.Join(outdir, manifestFile), manifest); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err.Error())
+		os.Exit(1)
+	}
+	if err := writeJSON(filepath.Join(outdir, regression.IndexFile), index); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err.Error())
+		os.Exit(1)
+	}
+	fmt.Println("Built", len(pending), "databases,", len(jobs)-len(pending), "unchanged,", removed, "removed")
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/radareorg/r2r-go/regression"
+)
+
+const (
+	BaselineNewlyFailing = "newly failing"
+	BaselineNewlyPassing = "newly passing"
+	BaselineStillFailing = "still failing"
+	BaselineUnchanged    = "unchanged"
+)
+
+// classify compares whether a test failed the run with what is stored in the
+// baseline; tests missing from the baseline are considered as passing there.
+func classify(previous bool, failed bool) string {
+	switch {
+	case failed && !previous:
+		return BaselineNewlyFailing
+	case !failed && previous:
+		return BaselineNewlyPassing
+	case failed:
+		return BaselineStillFailing
+	}
+	return BaselineUnchanged
+}
+
+// compareBaseline prints how the results changed since the baseline and
+// returns false only when there are new regressions.
+func compareBaseline(options *TestsOptions, baseline R2ResultsReport, results []*regression.TestResult) bool {
+	prefix := ""
+	if options.Format == "tap" {
+		prefix = "# "
+	}
+	previous := make(map[string]bool, len(baseline.Tests))
+	for _, report := range baseline.Tests {
+		previous[report.Key()] = report.Failed
+	}
+	counts := make(map[string]int)
+	changes := make(map[string][]*regression.TestResult)
+	for _, result := range results {
+		class := classify(previous[result.Test.Key()], result.Failed())
+		counts[class]++
+		changes[class] = append(changes[class], result)
+	}
+	fmt.Println(prefix + "Baseline comparison:")
+	for _, class := range []string{BaselineNewlyFailing, BaselineNewlyPassing, BaselineStillFailing, BaselineUnchanged} {
+		fmt.Printf("%s  %-15s %6d\n", prefix, class+":", counts[class])
+	}
+	for _, class := range []string{BaselineNewlyFailing, BaselineNewlyPassing} {
+		if len(changes[class]) < 1 {
+			continue
+		}
+		fmt.Println(prefix + "Tests " + class + ":")
+		for _, result := range changes[class] {
+			location := result.Test.Location()
+			if location == "" {
+				location = path.Base(result.Test.Database)
+			}
+			fmt.Printf("%s  [%s] %s (%s)\n", prefix, result.Status(), result.Test.Name, location)
+		}
+	}
+	return counts[BaselineNewlyFailing] == 0
+}
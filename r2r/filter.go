@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/radareorg/r2r-go/regression"
+)
+
+func compileFilter(expr string) *regexp.Regexp {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return re
+}
+
+func matchesAny(test regression.R2Test, expressions []*regexp.Regexp) bool {
+	for _, re := range expressions {
+		if test.Matches(re) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterTests keeps the tests matching at least one of the --filter regexes
+// (when there is any) and none of the --exclude ones.
+func filterTests(options *TestsOptions, tests []regression.R2Test) []regression.R2Test {
+	if len(options.Filters) < 1 && len(options.Excludes) < 1 {
+		return tests
+	}
+	var selected []regression.R2Test
+	for _, test := range tests {
+		if len(options.Filters) > 0 && !matchesAny(test, options.Filters) {
+			continue
+		}
+		if matchesAny(test, options.Excludes) {
+			continue
+		}
+		selected = append(selected, test)
+	}
+	return selected
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/radareorg/r2r-go/regression"
+)
+
+type expectationFix struct {
+	Expected    string
+	ExpectedErr string
+	Accept      bool
+	Broken      bool
+}
+
+func ask(reader *bufio.Reader, question string, choices string) byte {
+	for {
+		fmt.Print(question)
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if len(line) == 1 && strings.IndexByte(choices, line[0]) >= 0 {
+			return line[0]
+		}
+		if err != nil {
+			fmt.Println()
+			return 'q'
+		}
+	}
+}
+
+// fixExpectations shows every failing test and asks whether its output has to
+// be accepted as the new expectation or the test has to be marked as broken;
+// the databases are then rewritten with the accepted changes. Relative paths
+// of the databases are resolved against origin.
+func fixExpectations(results []*regression.TestResult, origin string) error {
+	reader := bufio.NewReader(os.Stdin)
+	fixes := make(map[string]map[int]expectationFix)
+	var databases []string
+	for _, result := range results {
+		if !result.Failed() || result.Skipped {
+			continue
+		}
+		fmt.Println("[XX]", result.Test.Name, "("+filepath.Base(result.Test.Database)+")")
+		fmt.Println("r2", result.Test.Args, result.Test.File)
+		printLocation(result.Test)
+		fmt.Println(result.Message)
+		if result.Test.ExpectedErr == "" {
+			printStderr(result)
+		}
+		var answer byte
+		if result.Error {
+			answer = ask(reader, "[s]kip, mark [b]roken, [q]uit? ", "sbq")
+		} else {
+			answer = ask(reader, "[a]ccept, [s]kip, mark [b]roken, [q]uit? ", "asbq")
+		}
+		if answer == 'q' {
+			break
+		} else if answer == 's' {
+			continue
+		}
+		database := result.Test.Database
+		if _, ok := fixes[database]; !ok {
+			fixes[database] = make(map[int]expectationFix)
+			databases = append(databases, database)
+		}
+		fixes[database][result.Test.Index] = expectationFix{
+			Expected:    result.Output,
+			ExpectedErr: result.Stderr,
+			Accept:      answer == 'a',
+			Broken:      answer == 'b',
+		}
+	}
+	for _, database := range databases {
+		fpath := database
+		if !filepath.IsAbs(fpath) {
+			fpath = filepath.Join(origin, fpath)
+		}
+		if err := rewriteDatabase(fpath, fixes[database]); err != nil {
+			return err
+		}
+		fmt.Println("Updated", len(fixes[database]), "tests in", database)
+	}
+	return nil
+}
+
+// rewriteDatabase applies the fixes to the tests of the database, keeping the
+// same order and indentation used by r2r-build; the text databases are
+// written again with regression.ExportFile.
+func rewriteDatabase(fpath string, fixes map[int]expectationFix) error {
+	regressions, err := regression.LoadDatabase(fpath)
+	if err != nil {
+		return err
+	}
+	if len(regressions.Diagnostics) > 0 {
+		// what the parser did not understand would be lost.
+		return fmt.Errorf("%s cannot be rewritten: %s", fpath, regressions.Diagnostics[0])
+	}
+	for index, fix := range fixes {
+		if index >= len(regressions.Tests) {
+			return fmt.Errorf("%s has changed during the run", fpath)
+		}
+		if fix.Accept {
+			regressions.Tests[index].Expected = fix.Expected
+			// stderr is checked only by the tests that expect some.
+			if regressions.Tests[index].ExpectedErr != "" {
+				regressions.Tests[index].ExpectedErr = fix.ExpectedErr
+			}
+		}
+		if fix.Broken {
+			regressions.Tests[index].Broken = true
+		}
+	}
+	if !strings.HasSuffix(fpath, ".json") {
+		return regression.ExportFile(fpath, regressions)
+	}
+	bytes, err := json.MarshalIndent(regressions, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fpath, bytes, 0644)
+}
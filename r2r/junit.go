@@ -0,0 +1,188 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/radareorg/r2r-go/regression"
+)
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Body    string `xml:",cdata"`
+}
+
+type junitProperties struct {
+	Property []junitProperty `xml:"property"`
+}
+
+type junitTestCase struct {
+	Name       string           `xml:"name,attr"`
+	ClassName  string           `xml:"classname,attr"`
+	Time       string           `xml:"time,attr"`
+	File       string           `xml:"file,attr,omitempty"`
+	Line       int              `xml:"line,attr,omitempty"`
+	Properties *junitProperties `xml:"properties,omitempty"`
+	Skipped    *junitMessage    `xml:"skipped,omitempty"`
+	Failure    *junitMessage    `xml:"failure,omitempty"`
+	Error      *junitMessage    `xml:"error,omitempty"`
+	SystemOut  string           `xml:"system-out,omitempty"`
+	SystemErr  string           `xml:"system-err,omitempty"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+	duration  time.Duration
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name          `xml:"testsuites"`
+	Suites  []*junitTestSuite `xml:"testsuite"`
+}
+
+func junitSeconds(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
+
+// xmlText drops the characters that are not allowed in XML 1.0, like the
+// escape sequences of the colored output of radare2, which CDATA sections do
+// not escape.
+func xmlText(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			return r
+		case r < 0x20, r >= 0xd800 && r < 0xe000, r == 0xfffe, r == 0xffff, r > 0x10ffff:
+			return -1
+		}
+		return r
+	}, text)
+}
+
+func (message *junitMessage) sanitize() {
+	if message != nil {
+		message.Message = xmlText(message.Message)
+		message.Body = xmlText(message.Body)
+	}
+}
+
+func newJUnitTestCase(result *regression.TestResult) junitTestCase {
+	testcase := junitTestCase{
+		Name:      result.Test.Name,
+		ClassName: result.Test.Suite(),
+		Time:      junitSeconds(result.Duration),
+		File:      result.Test.Source,
+		Line:      result.Test.Line,
+		SystemErr: result.Stderr,
+	}
+	switch result.Status() {
+	case regression.StatusFixed:
+		testcase.Properties = &junitProperties{[]junitProperty{{"fixed", "true"}}}
+		testcase.SystemOut = "test is marked as broken, but it passes."
+	case regression.StatusFlaky:
+		testcase.Properties = &junitProperties{[]junitProperty{{"flaky", "true"}}}
+		testcase.SystemOut = fmt.Sprintf("test passed after %d attempts.\n%s", len(result.Attempts), result.Message)
+	case regression.StatusSkipped:
+		if result.Options.StrictFixtures {
+			testcase.Error = &junitMessage{result.Message, "skipped", ""}
+		} else {
+			testcase.Skipped = &junitMessage{result.Message, "", ""}
+		}
+	case regression.StatusBroken:
+		testcase.Skipped = &junitMessage{"test is marked as broken", "", ""}
+	case regression.StatusFailed:
+		testcase.Failure = &junitMessage{"unexpected output", "diff", result.Message}
+	case regression.StatusDiffers:
+		testcase.Failure = &junitMessage{"the outputs of the two radare2 builds differ", "differs", result.Message}
+	case regression.StatusCrash:
+		testcase.Error = &junitMessage{"radare2 crashed", "crash", result.Message}
+	case regression.StatusMemory:
+		testcase.Error = &junitMessage{"memory errors detected", "memory", result.MemoryReport() + "\n" + result.Message}
+	case regression.StatusTimeout:
+		testcase.Error = &junitMessage{result.Message, "timeout", ""}
+	case regression.StatusError:
+		testcase.Error = &junitMessage{"something went really wrong", "error", result.Message}
+	}
+	testcase.Name = xmlText(testcase.Name)
+	testcase.SystemOut = xmlText(testcase.SystemOut)
+	testcase.SystemErr = xmlText(testcase.SystemErr)
+	testcase.Skipped.sanitize()
+	testcase.Failure.sanitize()
+	testcase.Error.sanitize()
+	return testcase
+}
+
+// writeJUnit writes the results as a JUnit XML report, with one testsuite
+// for each database.
+func writeJUnit(fpath string, results []*regression.TestResult) error {
+	var report junitTestSuites
+	suites := make(map[string]*junitTestSuite)
+	for _, result := range results {
+		suite, ok := suites[result.Test.Database]
+		if !ok {
+			suite = &junitTestSuite{Name: result.Test.Suite()}
+			suites[result.Test.Database] = suite
+			report.Suites = append(report.Suites, suite)
+		}
+		testcase := newJUnitTestCase(result)
+		suite.Tests++
+		if testcase.Skipped != nil {
+			suite.Skipped++
+		} else if testcase.Failure != nil {
+			suite.Failures++
+		} else if testcase.Error != nil {
+			suite.Errors++
+		}
+		suite.duration += result.Duration
+		suite.TestCases = append(suite.TestCases, testcase)
+	}
+	for _, suite := range report.Suites {
+		suite.Time = junitSeconds(suite.duration)
+	}
+	bytes, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fpath, append([]byte(xml.Header), append(bytes, '\n')...), 0644)
+}
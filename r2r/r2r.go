@@ -27,40 +27,75 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
 	"runtime"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/radareorg/r2r-go/regression"
 )
 
+// TestsOptions extends the options of the runner with the ones of the command
+// line: which tests to run and how to report their results.
+type TestsOptions struct {
+	regression.Options
+	ErrorsOnly  bool
+	JUnit       string
+	Results     string
+	Format      string
+	WorkDir     string
+	Slowest     int
+	Filters     []*regexp.Regexp
+	Excludes    []*regexp.Regexp
+	Interactive bool
+	Baseline    string
+	Shard       int
+	Shards      int
+	Timings     string
+}
+
 type ArgOption struct {
 	Description string
 	Argc        int
 	Callback    func(...string)
 }
 
-func loadJSON(fpath string) R2RegressionTest {
-	raw, err := ioutil.ReadFile(fpath)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", err.Error())
-		os.Exit(1)
+// absPath resolves fpath against the directory r2r has been started from,
+// because the working directory is changed by --wdir before running the tests.
+func absPath(fpath string) string {
+	if fpath == "" {
+		return fpath
 	}
-	var tests R2RegressionTest
-
-	if err := json.Unmarshal(raw, &tests); err != nil {
+	abs, err := filepath.Abs(fpath)
+	if err != nil {
 		fmt.Fprintln(os.Stderr, "Error:", err.Error())
 		os.Exit(1)
 	}
-	return tests
+	return abs
 }
 
 var options TestsOptions = TestsOptions{
-	false,
-	false,
-	false,
-	runtime.NumCPU(),
+	Options: regression.Options{
+		Jobs:    runtime.NumCPU(),
+		Radare2: radare2Path(),
+	},
+	Slowest: 5,
+	Timings: ".r2r-timings.json",
+}
+
+// radare2Path returns the radare2 executable set by the R2R_RADARE2
+// environment variable, if any.
+func radare2Path() string {
+	if r2bin := os.Getenv("R2R_RADARE2"); r2bin != "" {
+		return r2bin
+	}
+	return "radare2"
 }
 
 var ArgsOptions = map[string]ArgOption{
@@ -76,14 +111,161 @@ var ArgsOptions = map[string]ArgOption{
 			options.Jobs = s
 		},
 	},
-	"--wdir": {
-		"changes the current working directory",
+	"--timeout": {
+		"kills radare2 after n seconds; TIMEOUT in a test overrides it. (if n = 0 then tests never time out).",
+		1,
+		func(value ...string) {
+			s, err := strconv.Atoi(value[0])
+			if err != nil || s < 0 {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			options.Timeout = s
+		},
+	},
+	"--junit": {
+		"writes a JUnit XML report of the run into the given file",
+		1,
+		func(value ...string) {
+			options.JUnit = value[0]
+		},
+	},
+	"--results": {
+		"writes the results of every test (with timings) as JSON into the given file",
+		1,
+		func(value ...string) {
+			options.Results = value[0]
+		},
+	},
+	"--format": {
+		"selects the output format of the results: default or tap",
+		1,
+		func(value ...string) {
+			if value[0] != "default" && value[0] != "tap" {
+				fmt.Println("Unknown format", value[0])
+				os.Exit(1)
+			}
+			options.Format = value[0]
+		},
+	},
+	"--slowest": {
+		"lists the n slowest tests at the end of the run. (if n = 0 then the list is not printed).",
+		1,
+		func(value ...string) {
+			s, err := strconv.Atoi(value[0])
+			if err != nil || s < 0 {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			options.Slowest = s
+		},
+	},
+	"--filter": {
+		"runs only the tests whose name, file or args match the regex (can be repeated)",
+		1,
+		func(value ...string) {
+			options.Filters = append(options.Filters, compileFilter(value[0]))
+		},
+	},
+	"--exclude": {
+		"skips the tests whose name, file or args match the regex (can be repeated)",
+		1,
+		func(value ...string) {
+			options.Excludes = append(options.Excludes, compileFilter(value[0]))
+		},
+	},
+	"--interactive": {
+		"asks how to fix each failing test and updates its database",
+		0,
+		func(value ...string) {
+			options.Interactive = true
+		},
+	},
+	"--reuse": {
+		"keeps radare2 running between the tests with the same args and file, resetting its state",
+		0,
+		func(value ...string) {
+			options.Reuse = true
+		},
+	},
+	"--baseline": {
+		"compares the run with a --results file and fails only on new regressions",
 		1,
 		func(value ...string) {
-			if err := os.Chdir(value[0]); err != nil {
+			options.Baseline = value[0]
+		},
+	},
+	"--retries": {
+		"executes a failing test up to n more times, reporting it as flaky when an attempt passes",
+		1,
+		func(value ...string) {
+			s, err := strconv.Atoi(value[0])
+			if err != nil || s < 0 {
 				fmt.Println(err)
 				os.Exit(1)
 			}
+			options.Retries = s
+		},
+	},
+	"--shard": {
+		"runs only the i-th of n deterministic partitions of the tests (i/n, 1 <= i <= n)",
+		1,
+		func(value ...string) {
+			var err error
+			options.Shard, options.Shards, err = parseShard(value[0])
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		},
+	},
+	"--timings": {
+		"stores the duration of the tests in the given file, used to run the slowest tests first",
+		1,
+		func(value ...string) {
+			options.Timings = value[0]
+		},
+	},
+	"--no-timings": {
+		"disables the scheduling based on the durations of the previous runs",
+		0,
+		func(value ...string) {
+			options.Timings = ""
+		},
+	},
+	"--r2": {
+		"selects the radare2 executable to test (default: $R2R_RADARE2 or radare2)",
+		1,
+		func(value ...string) {
+			options.Radare2 = value[0]
+		},
+	},
+	"--compare-with": {
+		"runs the tests also with another radare2 executable and reports the outputs that differ",
+		1,
+		func(value ...string) {
+			options.CompareWith = value[0]
+		},
+	},
+	"--wrapper": {
+		"runs radare2 under a command like \"valgrind -q\" and reports the memory errors it finds",
+		1,
+		func(value ...string) {
+			options.Wrapper = strings.Fields(value[0])
+		},
+	},
+	"--strict-fixtures": {
+		"makes the tests whose file is missing fail instead of being skipped",
+		0,
+		func(value ...string) {
+			options.StrictFixtures = true
+		},
+	},
+	"--wdir": {
+		"changes the current working directory before running the tests",
+		1,
+		func(value ...string) {
+			options.WorkDir = value[0]
 		},
 	},
 	"--debug": {
@@ -94,7 +276,7 @@ var ArgsOptions = map[string]ArgOption{
 		},
 	},
 	"--seq": {
-		"enables sequence output",
+		"prints the results in the same order the tests are loaded",
 		0,
 		func(value ...string) {
 			options.Sequence = true
@@ -110,7 +292,8 @@ var ArgsOptions = map[string]ArgOption{
 }
 
 func usage() {
-	fmt.Println("Usage: ")
+	fmt.Println("Usage:", os.Args[0], "[options] <file.json|directory>...")
+	fmt.Println("      ", os.Args[0], "merge <out.json> <results.json>...")
 	for k, v := range ArgsOptions {
 		fmt.Printf("%15s | %s (%d args)\n", k, v.Description, v.Argc)
 	}
@@ -122,21 +305,39 @@ func badarg(arg string) {
 	os.Exit(1)
 }
 
+// executablePath resolves relative paths of executables before --wdir,
+// plain names are left to be searched in $PATH.
+func executablePath(fpath string) string {
+	if !strings.ContainsRune(fpath, filepath.Separator) {
+		return fpath
+	}
+	return absPath(fpath)
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "merge" {
+		merge(os.Args[2:])
+		return
+	}
 	if len(os.Args) < 2 {
-		fmt.Println(string(os.Args[0]), "[options] <file.json>")
+		fmt.Println(string(os.Args[0]), "[options] <file.json|directory>...")
 		os.Exit(1)
 	}
+	var paths []string
 	Argc := len(os.Args)
-	for i := 1; i < (Argc - 1); i++ {
+	for i := 1; i < Argc; i++ {
 		arg := string(os.Args[i])
 		if arg == "--help" || arg == "-h" {
 			usage()
 		}
+		if !strings.HasPrefix(arg, "-") {
+			paths = append(paths, arg)
+			continue
+		}
 		pair, ok := ArgsOptions[arg]
 		if ok {
 			max := i + pair.Argc + 1
-			if max < Argc {
+			if max <= Argc {
 				args := os.Args[i+1 : max]
 				pair.Callback(args...)
 				i = max - 1
@@ -147,15 +348,112 @@ func main() {
 			badarg(arg)
 		}
 	}
-	filepath := string(os.Args[Argc-1])
-	if filepath == "--help" || filepath == "-h" {
-		usage()
+	if len(paths) < 1 {
+		fmt.Println(string(os.Args[0]), "[options] <file.json|directory>...")
+		os.Exit(1)
+	}
+
+	var tests []regression.R2Test
+	databases, err := regression.CollectDatabases(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err.Error())
+		os.Exit(1)
+	}
+	for _, database := range databases {
+		regressions, err := regression.LoadDatabase(database)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err.Error())
+			os.Exit(1)
+		}
+		for _, diag := range regressions.Diagnostics {
+			options.Println(diag)
+		}
+		tests = append(tests, regressions.Tests...)
+	}
+	loaded := len(tests)
+	tests = filterTests(&options, tests)
+	tests = shardTests(&options, tests)
+	if options.Format != "tap" {
+		fmt.Println("Executing", len(tests), "tests from", len(databases), "databases")
+		if loaded != len(tests) {
+			fmt.Println(loaded-len(tests), "tests filtered out or in other shards")
+		}
+	}
+
+	var baseline R2ResultsReport
+	if options.Baseline != "" {
+		var err error
+		if baseline, err = loadResults(options.Baseline); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err.Error())
+			os.Exit(1)
+		}
 	}
-	fmt.Println("Executing", filepath)
 
-	tests := loadJSON(filepath)
-	pool := NewR2Pool(&options)
-	if !pool.PerformTests(&tests) {
+	origin := absPath(".")
+	options.JUnit = absPath(options.JUnit)
+	options.Results = absPath(options.Results)
+	options.Timings = absPath(options.Timings)
+	options.Radare2 = executablePath(options.Radare2)
+	options.CompareWith = executablePath(options.CompareWith)
+	if len(options.Wrapper) > 0 {
+		options.Wrapper[0] = executablePath(options.Wrapper[0])
+	}
+	if options.WorkDir != "" {
+		if err := os.Chdir(options.WorkDir); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		fmt.Println("Interrupted, stopping the running tests..")
+		cancel()
+	}()
+
+	start := time.Now()
+	runner := regression.NewRunner(&options.Options)
+	reporter := NewReporter(&options)
+	runner.OnStart = reporter.Start
+	runner.OnResult = reporter.Report
+	if options.Timings != "" {
+		if runner.Timings, err = regression.LoadTimings(options.Timings); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: ignoring", options.Timings+":", err.Error())
+		}
+		runner.OnFinish = func(results []*regression.TestResult) {
+			runner.Timings.Update(results)
+			if err := runner.Timings.Save(options.Timings); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err.Error())
+			}
+		}
+	}
+	results, success := runner.Run(ctx, tests)
+	printSummary(&options, results, time.Since(start))
+	if options.Baseline != "" {
+		success = compareBaseline(&options, baseline, results) && ctx.Err() == nil
+	}
+	if options.JUnit != "" {
+		if err := writeJUnit(options.JUnit, results); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err.Error())
+			os.Exit(1)
+		}
+	}
+	if options.Results != "" {
+		if err := writeResults(options.Results, results); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err.Error())
+			os.Exit(1)
+		}
+	}
+	if options.Interactive && ctx.Err() == nil {
+		if err := fixExpectations(results, origin); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err.Error())
+			os.Exit(1)
+		}
+	}
+	if !success {
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,206 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/radareorg/r2r-go/regression"
+)
+
+// A Reporter outputs the results of the tests while the runner collects them.
+type Reporter interface {
+	Start(total int)
+	Report(result *regression.TestResult)
+}
+
+func NewReporter(options *TestsOptions) Reporter {
+	if options.Format == "tap" {
+		return &TAPReporter{}
+	}
+	return &ConsoleReporter{options}
+}
+
+// ConsoleReporter prints the classic [OK]/[XX]/[BR]/[FX] lines.
+type ConsoleReporter struct {
+	options *TestsOptions
+}
+
+func (reporter *ConsoleReporter) Start(total int) {}
+
+func (reporter *ConsoleReporter) Report(result *regression.TestResult) {
+	printResult(reporter.options, result)
+}
+
+// printStderr prints the end of the stderr of radare2, when there is any.
+func printStderr(result *regression.TestResult) {
+	if result.Stderr != "" {
+		fmt.Println("stderr:")
+		fmt.Println(result.StderrTail())
+	}
+}
+
+// printLocation prints where the test is defined, when it is known.
+func printLocation(test *regression.R2Test) {
+	if location := test.Location(); location != "" {
+		fmt.Println(location)
+	}
+}
+
+// printResult prints the outcome of the test; with --errors-only the passing
+// and broken tests are not shown, nor the skipped ones without
+// --strict-fixtures.
+func printResult(options *TestsOptions, result *regression.TestResult) {
+	if result.Skipped {
+		if options.StrictFixtures || !options.ErrorsOnly {
+			fmt.Println("[SK]", result.Test.Name, result.Message)
+		}
+	} else if result.Flaky {
+		fmt.Printf("[FL] %s (passed after %d attempts)\n", result.Test.Name, len(result.Attempts))
+		options.Println(result.Message)
+	} else if len(result.MemoryErrors) > 0 {
+		fmt.Println("[ME]", result.Test.Name)
+		printLocation(result.Test)
+		options.Println("r2", result.Test.Args, result.Test.File)
+		fmt.Println(result.MemoryReport())
+		if result.Message != "" {
+			fmt.Println(result.Message)
+		}
+	} else if result.Timeout {
+		fmt.Println("[TO]", result.Test.Name, result.Message)
+		printLocation(result.Test)
+		options.Println("r2", result.Test.Args, result.Test.File)
+		options.Println(strings.Join(result.Test.Commands, "; "))
+	} else if result.Crashed {
+		fmt.Println("[CR]", result.Test.Name)
+		printLocation(result.Test)
+		options.Println("r2", result.Test.Args, result.Test.File)
+		fmt.Println(result.Message)
+		printStderr(result)
+	} else if result.Error {
+		fmt.Println("[XX]", result.Test.Name, "something went really wrong.")
+		printLocation(result.Test)
+		options.Println("r2", result.Test.Args, result.Test.File)
+		options.Println(strings.Join(result.Test.Commands, "; "))
+		fmt.Println(result.Message)
+		printStderr(result)
+	} else if result.Compared {
+		if !result.Success {
+			fmt.Println("[DF]", result.Test.Name)
+			printLocation(result.Test)
+			options.Println("r2", result.Test.Args, result.Test.File)
+			fmt.Println(result.Message)
+		} else if !options.ErrorsOnly {
+			fmt.Println("[OK]", result.Test.Name)
+		}
+	} else if result.Success {
+		if result.Test.Broken {
+			fmt.Println("[FX]", result.Test.Name)
+		} else if !options.ErrorsOnly {
+			fmt.Println("[OK]", result.Test.Name)
+		}
+	} else if result.Test.Broken {
+		if !options.ErrorsOnly {
+			fmt.Println("[BR]", result.Test.Name)
+		}
+	} else {
+		fmt.Println("[XX]", result.Test.Name)
+		printLocation(result.Test)
+		options.Println("r2", result.Test.Args, result.Test.File)
+		fmt.Println(result.Message)
+		if result.Test.ExpectedErr == "" {
+			printStderr(result)
+		}
+	}
+}
+
+// TAPReporter prints the results following the Test Anything Protocol
+// (version 13), with the diffs and errors as YAML diagnostics.
+type TAPReporter struct {
+	count int
+}
+
+func (reporter *TAPReporter) Start(total int) {
+	fmt.Println("TAP version 13")
+	fmt.Printf("1..%d\n", total)
+}
+
+func tapEscape(name string) string {
+	name = strings.Replace(name, "\\", "\\\\", -1)
+	return strings.Replace(name, "#", "\\#", -1)
+}
+
+func tapBlock(key, text string) {
+	fmt.Printf("  %s: |\n", key)
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		fmt.Println("    " + line)
+	}
+}
+
+func (reporter *TAPReporter) Report(result *regression.TestResult) {
+	reporter.count++
+	line := fmt.Sprintf("%d - %s", reporter.count, tapEscape(result.Test.Name))
+	status := result.Status()
+	switch {
+	case result.Skipped && !result.Options.StrictFixtures:
+		fmt.Printf("ok %s # SKIP %s\n", line, result.Message)
+		return
+	case status == regression.StatusOk:
+		fmt.Println("ok", line)
+		return
+	case status == regression.StatusFlaky:
+		fmt.Println("ok", line)
+		fmt.Printf("  # flaky, passed after %d attempts\n", len(result.Attempts))
+		return
+	case status == regression.StatusFixed:
+		fmt.Printf("ok %s # TODO broken\n", line)
+		return
+	case status == regression.StatusBroken:
+		fmt.Printf("not ok %s # TODO broken\n", line)
+		return
+	}
+	fmt.Println("not ok", line)
+	fmt.Println("  ---")
+	fmt.Println("  status:", status)
+	fmt.Printf("  command: %q\n", "r2 "+result.Test.Args+" "+result.Test.File)
+	if location := result.Test.Location(); location != "" {
+		fmt.Printf("  source: %q\n", location)
+	}
+	if status == regression.StatusFailed || status == regression.StatusDiffers {
+		tapBlock("diff", result.Message)
+	} else {
+		tapBlock("message", result.Message)
+	}
+	if len(result.MemoryErrors) > 0 {
+		tapBlock("memory", result.MemoryReport())
+	}
+	if result.Stderr != "" {
+		tapBlock("stderr", result.StderrTail())
+	}
+	fmt.Println("  ...")
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/radareorg/r2r-go/regression"
+)
+
+// R2TestReport is the serialized form of a regression.TestResult. The fields of the test
+// are the same read by regression.LoadJSON, so a report can be joined back with its
+// database.
+type R2TestReport struct {
+	regression.R2Test
+	Database string `json:"database"`
+	R2AttemptReport
+	Attempts []R2AttemptReport `json:"attempts,omitempty"`
+}
+
+// R2AttemptReport holds the outcome of a single execution of a test.
+type R2AttemptReport struct {
+	Status   string  `json:"status"`
+	Failed   bool    `json:"failed"`   // whether it made the run fail
+	Duration float64 `json:"duration"` // seconds
+	Output   string  `json:"output"`
+	Diff     string  `json:"diff"`
+	Error    string  `json:"error"`
+	Stderr   string  `json:"stderr"`
+
+	MemoryErrors []regression.MemoryError `json:"memory_errors,omitempty"`
+}
+
+type R2ResultsReport struct {
+	Tests []R2TestReport `json:"tests"`
+}
+
+func NewR2AttemptReport(result *regression.TestResult) R2AttemptReport {
+	report := R2AttemptReport{
+		Status:   result.Status(),
+		Failed:   result.Failed(),
+		Duration: result.Duration.Seconds(),
+		Output:   result.Output,
+		Stderr:   result.Stderr,
+
+		MemoryErrors: result.MemoryErrors,
+	}
+	if result.Error || result.Skipped {
+		report.Error = result.Message
+	} else if !result.Success {
+		report.Diff = result.Message
+	}
+	return report
+}
+
+func NewR2TestReport(result *regression.TestResult) R2TestReport {
+	report := R2TestReport{
+		R2Test:          *result.Test,
+		Database:        result.Test.Database,
+		R2AttemptReport: NewR2AttemptReport(result),
+	}
+	for _, attempt := range result.Attempts {
+		report.Attempts = append(report.Attempts, NewR2AttemptReport(attempt))
+	}
+	return report
+}
+
+// writeResults serializes all the results as JSON into the given file.
+func writeResults(fpath string, results []*regression.TestResult) error {
+	var report R2ResultsReport
+	report.Tests = make([]R2TestReport, 0, len(results))
+	for _, result := range results {
+		report.Tests = append(report.Tests, NewR2TestReport(result))
+	}
+	bytes, err := json.MarshalIndent(report, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fpath, bytes, 0644)
+}
+
+// loadResults reads a file written by writeResults.
+func loadResults(fpath string) (R2ResultsReport, error) {
+	var report R2ResultsReport
+	raw, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return report, err
+	}
+	if err := json.Unmarshal(raw, &report); err != nil {
+		return report, err
+	}
+	for i := range report.Tests {
+		report.Tests[i].R2Test.Database = report.Tests[i].Database
+	}
+	return report, nil
+}
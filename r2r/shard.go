@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/radareorg/r2r-go/regression"
+)
+
+func parseShard(value string) (int, int, error) {
+	pair := strings.SplitN(value, "/", 2)
+	if len(pair) != 2 {
+		return 0, 0, fmt.Errorf("invalid shard '%s', expected i/n", value)
+	}
+	shard, err := strconv.Atoi(pair[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	shards, err := strconv.Atoi(pair[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	if shards < 1 || shard < 1 || shard > shards {
+		return 0, 0, fmt.Errorf("invalid shard '%s', expected 1 <= i <= n", value)
+	}
+	return shard, shards, nil
+}
+
+// shardTests keeps only the tests of the --shard partition.
+func shardTests(options *TestsOptions, tests []regression.R2Test) []regression.R2Test {
+	if options.Shards < 2 {
+		return tests
+	}
+	var selected []regression.R2Test
+	for _, test := range tests {
+		if test.Shard(options.Shards) == options.Shard {
+			selected = append(selected, test)
+		}
+	}
+	return selected
+}
+
+// merge combines the --results files of the shards into a single one and
+// prints how many tests ended with each status.
+func merge(args []string) {
+	if len(args) < 2 {
+		fmt.Println(os.Args[0], "merge <out.json> <results.json>...")
+		os.Exit(1)
+	}
+	var merged R2ResultsReport
+	merged.Tests = make([]R2TestReport, 0)
+	for _, fpath := range args[1:] {
+		report, err := loadResults(fpath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err.Error())
+			os.Exit(1)
+		}
+		merged.Tests = append(merged.Tests, report.Tests...)
+	}
+	bytes, err := json.MarshalIndent(merged, "", "    ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err.Error())
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(args[0], bytes, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err.Error())
+		os.Exit(1)
+	}
+
+	failures := 0
+	statuses := make(map[string]int)
+	for _, report := range merged.Tests {
+		statuses[report.Status]++
+		if report.Failed {
+			failures++
+		}
+	}
+	fmt.Println("Merged", len(merged.Tests), "tests from", len(args)-1, "files into", args[0])
+	fmt.Println("  " + statusTotals(statuses))
+	if failures > 0 {
+		os.Exit(1)
+	}
+}
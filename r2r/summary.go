@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/radareorg/r2r-go/regression"
+)
+
+type R2Summary struct {
+	Database string
+	Tests    int
+	Failures int
+	Statuses map[string]int
+	Duration time.Duration
+}
+
+func (summary *R2Summary) Add(result *regression.TestResult) {
+	if summary.Statuses == nil {
+		summary.Statuses = make(map[string]int)
+	}
+	summary.Tests++
+	if result.Failed() {
+		summary.Failures++
+	}
+	summary.Statuses[result.Status()]++
+	summary.Duration += result.Duration
+}
+
+// statusTotals formats how many tests ended with each status.
+func statusTotals(statuses map[string]int) string {
+	totals := fmt.Sprintf("OK: %d, Failed: %d, Broken: %d, Fixed: %d, Flaky: %d, Crashes: %d, Errors: %d (timeouts: %d)",
+		statuses[regression.StatusOk], statuses[regression.StatusFailed], statuses[regression.StatusBroken],
+		statuses[regression.StatusFixed], statuses[regression.StatusFlaky], statuses[regression.StatusCrash],
+		statuses[regression.StatusError]+statuses[regression.StatusTimeout], statuses[regression.StatusTimeout])
+	if statuses[regression.StatusDiffers] > 0 {
+		totals += fmt.Sprintf(", Differs: %d", statuses[regression.StatusDiffers])
+	}
+	if statuses[regression.StatusSkipped] > 0 {
+		totals += fmt.Sprintf(", Skipped: %d", statuses[regression.StatusSkipped])
+	}
+	if statuses[regression.StatusMemory] > 0 {
+		totals += fmt.Sprintf(", Memory: %d", statuses[regression.StatusMemory])
+	}
+	return totals
+}
+
+// printSummary prints the number of tests and failures of each database and
+// the totals of the whole run followed by the slowest tests; with the tap
+// format the lines are printed as comments.
+func printSummary(options *TestsOptions, results []*regression.TestResult, elapsed time.Duration) {
+	prefix := ""
+	if options.Format == "tap" {
+		prefix = "# "
+	}
+	var total R2Summary
+	var databases []*R2Summary
+	summaries := make(map[string]*R2Summary)
+	for _, result := range results {
+		summary, ok := summaries[result.Test.Database]
+		if !ok {
+			summary = &R2Summary{Database: result.Test.Database}
+			summaries[result.Test.Database] = summary
+			databases = append(databases, summary)
+		}
+		summary.Add(result)
+		total.Add(result)
+	}
+	fmt.Println(prefix + "Summary:")
+	for _, summary := range databases {
+		fmt.Printf("%s  %-30s %6d tests, %d failures\n", prefix, path.Base(summary.Database), summary.Tests, summary.Failures)
+	}
+	fmt.Printf("%s  %-30s %6d tests, %d failures\n", prefix, "Total", total.Tests, total.Failures)
+	fmt.Println(prefix + "  " + statusTotals(total.Statuses))
+	fmt.Printf("%s  Wall time: %s (tests: %s)\n", prefix, elapsed.Round(time.Millisecond), total.Duration.Round(time.Millisecond))
+
+	if signatures := regression.MemorySignatures(results); len(signatures) > 0 {
+		fmt.Println(prefix + "Memory errors:")
+		for _, signature := range signatures {
+			tests := signature.Tests
+			if len(tests) > 3 {
+				tests = append(tests[:3:3], "...")
+			}
+			fmt.Printf("%s  %4d  %s\n", prefix, len(signature.Tests), signature.Signature)
+			fmt.Printf("%s        %s\n", prefix, strings.Join(tests, ", "))
+		}
+	}
+
+	if options.Slowest < 1 || len(results) < 1 {
+		return
+	}
+	slowest := make([]*regression.TestResult, len(results))
+	copy(slowest, results)
+	sort.SliceStable(slowest, func(i, j int) bool {
+		return slowest[i].Duration > slowest[j].Duration
+	})
+	if len(slowest) > options.Slowest {
+		slowest = slowest[:options.Slowest]
+	}
+	fmt.Println(prefix + "Slowest tests:")
+	for _, result := range slowest {
+		fmt.Printf("%s  %10s  %s (%s)\n", prefix, result.Duration.Round(time.Millisecond), result.Test.Name, path.Base(result.Test.Database))
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package regression
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// stderrTailLines is how many of the last lines of stderr are shown with a
+// failure, which usually end with the reason of a crash.
+const stderrTailLines = 20
+
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGTRAP: "SIGTRAP",
+}
+
+func signalName(signal syscall.Signal) string {
+	if name, ok := signalNames[signal]; ok {
+		return name
+	}
+	return fmt.Sprintf("signal %d (%s)", int(signal), signal.String())
+}
+
+// exitSignal returns the signal that terminated radare2, if any.
+func exitSignal(state *os.ProcessState) (syscall.Signal, bool) {
+	if state == nil {
+		return 0, false
+	}
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return 0, false
+	}
+	return status.Signal(), true
+}
+
+// describeExit explains how radare2 terminated after a failure.
+func describeExit(state *os.ProcessState, killed bool, command string) string {
+	var text string
+	if killed {
+		text = "radare2 stopped answering and has been killed"
+	} else if signal, ok := exitSignal(state); ok {
+		text = "radare2 killed by " + signalName(signal)
+	} else if state != nil {
+		text = fmt.Sprintf("radare2 exited with code %d", state.ExitCode())
+	} else {
+		return ""
+	}
+	if command != "" {
+		text += fmt.Sprintf(" while executing '%s'", command)
+	}
+	return text
+}
+
+// lastLines returns the last count lines of text.
+func lastLines(text string, count int) string {
+	lines := strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n")
+	if len(lines) <= count {
+		return text
+	}
+	return fmt.Sprintf("[%d lines omitted]\n", len(lines)-count) + strings.Join(lines[len(lines)-count:], "") + "\n"
+}
+
+// StderrTail returns the end of the stderr of radare2, which is all that the
+// reports show of it; the whole of it is kept in Stderr.
+func (result TestResult) StderrTail() string {
+	return lastLines(result.Stderr, stderrTailLines)
+}
+
+// crashed records how radare2 terminated in the result; the result is a crash
+// when radare2 has been killed by a signal that was not sent by the runner.
+func (result *TestResult) crashed(state *os.ProcessState, killed bool, command string) {
+	description := describeExit(state, killed, command)
+	if description == "" {
+		return
+	}
+	_, signaled := exitSignal(state)
+	result.Crashed = signaled && !killed
+	result.Message = description
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package regression
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadJSON reads a database exported by r2r-build.
+func LoadJSON(fpath string) (*R2RegressionTest, error) {
+	raw, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+	var tests R2RegressionTest
+
+	if err := json.Unmarshal(raw, &tests); err != nil {
+		return nil, err
+	}
+	for i := range tests.Tests {
+		tests.Tests[i].Database = fpath
+		tests.Tests[i].Index = i
+	}
+	return &tests, nil
+}
+
+// LoadDatabase reads a JSON database or, for any other file, a text database
+// of radare2-regressions.
+func LoadDatabase(fpath string) (*R2RegressionTest, error) {
+	if strings.HasSuffix(fpath, ".json") {
+		return LoadJSON(fpath)
+	}
+	return ParseFile(fpath)
+}
+
+// IndexFile lists the databases that r2r-build generated in a directory.
+const IndexFile = "index.json"
+
+type R2IndexEntry struct {
+	File   string `json:"file"`
+	Source string `json:"source"`
+	Type   string `json:"type"`
+	Tests  int    `json:"tests"`
+}
+
+type R2Index struct {
+	Databases []R2IndexEntry `json:"databases"`
+}
+
+// LoadIndex reads the IndexFile of the directory dir.
+func LoadIndex(dir string) (*R2Index, error) {
+	raw, err := ioutil.ReadFile(filepath.Join(dir, IndexFile))
+	if err != nil {
+		return nil, err
+	}
+	var index R2Index
+	if err := json.Unmarshal(raw, &index); err != nil {
+		return nil, err
+	}
+	return &index, nil
+}
+
+// IsDatabase reports whether a file found in a directory is a database: the
+// JSON ones and the text ones, which have no extension.
+func IsDatabase(fpath string) bool {
+	name := filepath.Base(fpath)
+	return strings.HasSuffix(name, ".json") || (filepath.Ext(name) == "" && !strings.HasPrefix(name, "."))
+}
+
+// CollectDatabases expands the directories found in paths to the databases
+// they contain, or to the ones listed by their IndexFile if they have one.
+func CollectDatabases(paths []string) ([]string, error) {
+	var databases []string
+	for _, fpath := range paths {
+		info, err := os.Stat(fpath)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			databases = append(databases, fpath)
+			continue
+		}
+		if index, err := LoadIndex(fpath); err == nil {
+			for _, entry := range index.Databases {
+				databases = append(databases, filepath.Join(fpath, entry.File))
+			}
+			continue
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		root := fpath
+		err = filepath.Walk(fpath, func(fpath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() && fpath != root && strings.HasPrefix(info.Name(), ".") {
+				// skips .git and alike in a checkout of radare2-regressions
+				return filepath.SkipDir
+			}
+			if !info.IsDir() && IsDatabase(fpath) {
+				databases = append(databases, fpath)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return databases, nil
+}
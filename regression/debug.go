@@ -24,13 +24,13 @@
  * POSSIBILITY OF SUCH DAMAGE.
  */
 
-package main
+package regression
 
 import (
 	"fmt"
 )
 
-func (options TestsOptions) Println(a ...interface{}) {
+func (options Options) Println(a ...interface{}) {
 	if options.Debug {
 		fmt.Println(a...)
 	}
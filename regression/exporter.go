@@ -0,0 +1,201 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package regression
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// plain reports whether value can be written verbatim on a single line, where
+// it cannot start a heredoc.
+func plain(value string) bool {
+	if !utf8.ValidString(value) {
+		return false
+	}
+	for _, r := range value {
+		if r != '\t' && unicode.IsControl(r) {
+			return false
+		}
+	}
+	return !strings.HasPrefix(value, "<<")
+}
+
+// heredocable reports whether the lines of value can be written between
+// KEY=<<EOF and EOF.
+func heredocable(value string) bool {
+	for _, line := range strings.Split(value, "\n") {
+		if line == "EOF" || !plain(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// exportValue writes the value on a single line when possible, then as a
+// heredoc and otherwise encoded in base64, so that reading it back gives the
+// same value.
+func exportValue(w io.Writer, key, value string, commands bool) {
+	if !strings.HasPrefix(value, "'") && plain(value) {
+		fmt.Fprintf(w, "%s=%s\n", key, value)
+		return
+	}
+	body := value
+	if commands {
+		body += "\n"
+	}
+	if strings.HasSuffix(body, "\n") && heredocable(strings.TrimSuffix(body, "\n")) {
+		fmt.Fprintf(w, "%s=<<EOF\n%sEOF\n", key, body)
+		return
+	}
+	fmt.Fprintf(w, "%s64=%s\n", key, base64.StdEncoding.EncodeToString([]byte(value)))
+}
+
+func exportTest(w io.Writer, test R2Test) error {
+	for _, value := range []string{test.Name, test.File, test.Args} {
+		if !plain(value) {
+			return fmt.Errorf("test %q: %q cannot be written on a single line", test.Name, value)
+		}
+	}
+	fmt.Fprintf(w, "NAME=%s\n", test.Name)
+	fmt.Fprintf(w, "FILE=%s\n", test.File)
+	if test.Args != "" {
+		fmt.Fprintf(w, "ARGS=%s\n", test.Args)
+	}
+	if test.Timeout > 0 {
+		fmt.Fprintf(w, "TIMEOUT=%d\n", test.Timeout)
+	}
+	if test.Broken {
+		fmt.Fprintln(w, "BROKEN=1")
+	}
+	if len(test.Commands) > 0 {
+		exportValue(w, "CMDS", strings.Join(test.Commands, "\n"), true)
+	}
+	exportValue(w, "EXPECT", test.Expected, false)
+	if test.ExpectedErr != "" {
+		exportValue(w, "EXPECT_ERR", test.ExpectedErr, false)
+	}
+	fmt.Fprintln(w, "RUN")
+	return nil
+}
+
+// sameAsmTest reports whether the db/asm line parsed in a is the test b.
+func sameAsmTest(a, b R2Test) bool {
+	return a.Args == b.Args && a.File == b.File && a.Expected == b.Expected &&
+		a.Broken == b.Broken && strings.Join(a.Commands, "\n") == strings.Join(b.Commands, "\n")
+}
+
+// asmLine returns the db/asm line of the test: the one kept in its name when
+// it still describes the test, otherwise a new one built from its commands.
+func asmLine(test R2Test) (string, error) {
+	sep := strings.Index(test.Name, ": ")
+	if sep < 0 {
+		return "", fmt.Errorf("test %q: the name does not start with the architecture", test.Name)
+	}
+	arch := test.Name[:sep]
+	line := test.Name[sep+2:]
+	var parsed R2Test
+	if (&parser{}).populate_asm(arch, &parsed, line) && sameAsmTest(parsed, test) {
+		return line, nil
+	}
+
+	var flags, asm, hex, skip string
+	expected := strings.Split(test.Expected, "\n")
+	for i, cmd := range test.Commands {
+		switch {
+		case i == 0 && strings.HasPrefix(cmd, "s "):
+			skip = " " + cmd[2:]
+			expected = append([]string{""}, expected...)
+		case strings.HasPrefix(cmd, "pa "):
+			flags += "a"
+			asm = cmd[3:]
+			if i < len(expected) {
+				hex = expected[i]
+			}
+		case strings.HasPrefix(cmd, "pad "):
+			flags += "d"
+			hex = cmd[4:]
+			if i < len(expected) {
+				asm = expected[i]
+			}
+		}
+	}
+	if test.Broken {
+		flags += "B"
+	}
+	if strings.Contains(test.Args, "-e cfg.bigendian=true") {
+		flags += "E"
+	}
+	line = fmt.Sprintf("%s \"%s\" %s%s", flags, asm, hex, skip)
+	parsed = R2Test{}
+	if !(&parser{}).populate_asm(arch, &parsed, line) || !sameAsmTest(parsed, test) {
+		return "", fmt.Errorf("test %q cannot be written as a db/asm line", test.Name)
+	}
+	return line, nil
+}
+
+// Export writes the tests in the text format of radare2-regressions, or as
+// db/asm lines for the asm databases.
+func Export(writer io.Writer, regr *R2RegressionTest) error {
+	w := bufio.NewWriter(writer)
+	for i, test := range regr.Tests {
+		if regr.Type == "asm" {
+			line, err := asmLine(test)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(w, line)
+			continue
+		}
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if err := exportTest(w, test); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// ExportFile writes the tests to fpath in the text format.
+func ExportFile(fpath string, regr *R2RegressionTest) error {
+	file, err := os.Create(fpath)
+	if err != nil {
+		return err
+	}
+	if err := Export(file, regr); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
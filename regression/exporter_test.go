@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package regression
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// roundTrip exports the tests and parses them back as the database fpath.
+func roundTrip(t *testing.T, fpath string, regr *R2RegressionTest) *R2RegressionTest {
+	var text bytes.Buffer
+	if err := Export(&text, regr); err != nil {
+		t.Fatalf("Export: %s", err)
+	}
+	parsed, err := Parse(&text, fpath)
+	if err != nil {
+		t.Fatalf("Parse: %s", err)
+	}
+	if len(parsed.Diagnostics) > 0 {
+		t.Errorf("unexpected diagnostics %v in:\n%s", parsed.Diagnostics, text.String())
+	}
+	if len(parsed.Tests) != len(regr.Tests) {
+		t.Fatalf("got %d tests instead of %d from:\n%s", len(parsed.Tests), len(regr.Tests), text.String())
+	}
+	return parsed
+}
+
+// sameTest compares the tests without where they are defined, which changes
+// when they are written again.
+func sameTest(t *testing.T, got, want R2Test) {
+	for _, test := range []*R2Test{&got, &want} {
+		test.Source, test.Line, test.LineEnd = "", 0, 0
+		test.Database, test.Index = "", 0
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestExportCmd(t *testing.T) {
+	regr := &R2RegressionTest{Type: "cmd", Tests: []R2Test{
+		{Name: "plain", File: "-", Args: "-n", Commands: []string{"?e hello"}, Expected: "hello\n"},
+		{Name: "eof", File: "-", Commands: []string{"?e EOF", "EOF"}, Expected: "EOF\n"},
+		{Name: "eof line", File: "-", Commands: []string{"?e a"}, Expected: "a\nEOF\nb\n"},
+		{Name: "run", File: "-", Commands: []string{"RUN"}, Expected: "RUN"},
+		{Name: "run line", File: "-", Commands: []string{"?e a", "RUN"}, Expected: "a\nRUN\n"},
+		{Name: "quote", File: "-", Commands: []string{"'?e a"}, Expected: "'a'\n", ExpectedErr: "'"},
+		{Name: "heredoc", File: "-", Commands: []string{"<<EOF"}, Expected: "<<EOF\n", ExpectedErr: "<<"},
+		{Name: "no newline", File: "-", Commands: []string{"?e a", "?e b"}, Expected: "a\nb", ExpectedErr: "err"},
+		{Name: "empty", File: "-", Commands: []string{"?e"}, Expected: ""},
+		{Name: "options", File: "bins/elf/true", Args: "-A", Commands: []string{"pi 1"}, Expected: "nop\n", Broken: true, Timeout: 5},
+	}}
+	parsed := roundTrip(t, "db/cmd/roundtrip", regr)
+	if parsed.Type != "cmd" {
+		t.Errorf("got type %q", parsed.Type)
+	}
+	for i, test := range parsed.Tests {
+		sameTest(t, test, regr.Tests[i])
+	}
+}
+
+func TestExportAsm(t *testing.T) {
+	text := strings.Join([]string{
+		`a "nop" 90`,
+		`d "ret" c3 0x100`,
+		`dB "jmp 0" ebfe`,
+		`aE "nop" 90`,
+		`adB "push rbp" 55 0x10`,
+	}, "\n") + "\n"
+	regr, err := Parse(strings.NewReader(text), "db/asm/x86_64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := roundTrip(t, "db/asm/x86_64", regr)
+	if parsed.Type != "asm" {
+		t.Errorf("got type %q", parsed.Type)
+	}
+	for i, test := range parsed.Tests {
+		sameTest(t, test, regr.Tests[i])
+	}
+
+	// a name that is not a db/asm line anymore is written from the commands.
+	for i := range regr.Tests {
+		regr.Tests[i].Name = "x86_64: renamed"
+	}
+	parsed = roundTrip(t, "db/asm/x86_64", regr)
+	for i, test := range parsed.Tests {
+		test.Name = regr.Tests[i].Name
+		sameTest(t, test, regr.Tests[i])
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package regression
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var uriScheme = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://`)
+
+// fixturePath returns the path of the local file opened by radare2, or an
+// empty string when the test does not open one (stdin, malloc://, and the
+// other io plugins).
+func fixturePath(file string) string {
+	if strings.HasPrefix(file, "file://") {
+		return strings.TrimPrefix(file, "file://")
+	}
+	if file == "" || file == "-" || file == "--" || uriScheme.MatchString(file) {
+		return ""
+	}
+	return file
+}
+
+// MissingFixture explains why the file of the test is not available, or
+// returns an empty string if the test can run.
+func (test R2Test) MissingFixture() string {
+	fpath := fixturePath(test.File)
+	if fpath == "" {
+		return ""
+	}
+	if _, err := os.Stat(fpath); os.IsNotExist(err) {
+		return fmt.Sprintf("fixture %s is missing", fpath)
+	}
+	return ""
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package regression
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MemoryError is a report of AddressSanitizer, UndefinedBehaviorSanitizer or
+// valgrind found in the stderr of radare2.
+type MemoryError struct {
+	Tool   string   `json:"tool"`
+	Kind   string   `json:"kind"`
+	Frames []string `json:"frames"`
+}
+
+// Signature identifies the same error across tests using the topmost frames.
+func (memerr MemoryError) Signature() string {
+	frames := memerr.Frames
+	if len(frames) > 3 {
+		frames = frames[:3]
+	}
+	return memerr.Tool + ": " + memerr.Kind + " in " + strings.Join(frames, " < ")
+}
+
+var (
+	sanitizerHeader = regexp.MustCompile(`^==\d+==\s*ERROR: (\w+Sanitizer): (.+?)(?: on (?:address|unknown address).*)?$`)
+	sanitizerFrame  = regexp.MustCompile(`^\s*#\d+ 0x[0-9a-fA-F]+ in (\S+)`)
+	ubsanHeader     = regexp.MustCompile(`^(\S+:\d+(?::\d+)?): runtime error: ([^:]+)`)
+	valgrindHeader  = regexp.MustCompile(`^==\d+== (Invalid (?:read|write|free).*|Mismatched free.*|Conditional jump.*|Use of uninitialised.*|Syscall param.*|Source and destination overlap.*|\d[\d,]* bytes in [\d,]+ blocks are (?:definitely|possibly) lost)`)
+	valgrindFrame   = regexp.MustCompile(`^==\d+==\s+(?:at|by) 0x[0-9A-Fa-f]+: (\S+)`)
+	valgrindLeak    = regexp.MustCompile(`^\d[\d,]* bytes in [\d,]+ blocks are `)
+)
+
+// parseMemoryErrors extracts the memory errors reported in stderr, each one
+// with the functions of its first stack trace.
+func parseMemoryErrors(stderr string) []MemoryError {
+	var errors []MemoryError
+	// frame matches the stack trace of the last error, until it ends.
+	var frame *regexp.Regexp
+	for _, line := range strings.Split(stderr, "\n") {
+		if match := sanitizerHeader.FindStringSubmatch(line); match != nil {
+			errors = append(errors, MemoryError{match[1], match[2], nil})
+			frame = sanitizerFrame
+			continue
+		}
+		if match := ubsanHeader.FindStringSubmatch(line); match != nil {
+			errors = append(errors, MemoryError{"UndefinedBehaviorSanitizer", match[2], []string{match[1]}})
+			frame = sanitizerFrame
+			continue
+		}
+		if match := valgrindHeader.FindStringSubmatch(line); match != nil {
+			kind := valgrindLeak.ReplaceAllString(match[1], "")
+			errors = append(errors, MemoryError{"valgrind", kind, nil})
+			frame = valgrindFrame
+			continue
+		}
+		if frame == nil {
+			continue
+		}
+		last := &errors[len(errors)-1]
+		if match := frame.FindStringSubmatch(line); match != nil {
+			last.Frames = append(last.Frames, match[1])
+		} else if len(last.Frames) > 0 && (frame == valgrindFrame || strings.TrimSpace(line) == "") {
+			frame = nil
+		}
+	}
+	return errors
+}
+
+// MemoryReport formats the memory errors of the result, one per line.
+func (result TestResult) MemoryReport() string {
+	var lines []string
+	for _, memerr := range result.MemoryErrors {
+		lines = append(lines, memerr.Signature())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// NewPipe spawns r2bin with args, prefixed by the --wrapper command if any.
+func (options *Options) NewPipe(ctx context.Context, r2bin string, args []string) (*Pipe, error) {
+	if len(options.Wrapper) < 1 {
+		return NewPipeContext(ctx, r2bin, args...)
+	}
+	var wrapped []string
+	wrapped = append(wrapped, options.Wrapper[1:]...)
+	wrapped = append(wrapped, r2bin)
+	wrapped = append(wrapped, args...)
+	return NewPipeContext(ctx, options.Wrapper[0], wrapped...)
+}
+
+type MemorySignature struct {
+	Signature string
+	Tests     []string
+}
+
+// MemorySignatures groups the memory errors of all the results by their
+// signature, the most frequent first.
+func MemorySignatures(results []*TestResult) []*MemorySignature {
+	var signatures []*MemorySignature
+	bysignature := make(map[string]*MemorySignature)
+	for _, result := range results {
+		seen := make(map[string]bool)
+		for _, memerr := range result.MemoryErrors {
+			key := memerr.Signature()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			signature, ok := bysignature[key]
+			if !ok {
+				signature = &MemorySignature{Signature: key}
+				bysignature[key] = signature
+				signatures = append(signatures, signature)
+			}
+			signature.Tests = append(signature.Tests, result.Test.Name)
+		}
+	}
+	sort.SliceStable(signatures, func(i, j int) bool {
+		return len(signatures[i].Tests) > len(signatures[j].Tests)
+	})
+	return signatures
+}
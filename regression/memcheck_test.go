@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package regression
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMemoryErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr []string
+		errors []MemoryError
+	}{
+		{
+			name: "asan",
+			stderr: []string{
+				"=================================================================",
+				"==1234==ERROR: AddressSanitizer: heap-buffer-overflow on address 0x602000000011 at pc 0x55d3 bp 0x7ffc sp 0x7ff0",
+				"READ of size 1 at 0x602000000011 thread T0",
+				"    #0 0x55d3 in r_buf_read_at /src/libr/util/buf.c:10",
+				"    #1 0x55d4 in r_bin_open /src/libr/bin/bin.c:20",
+				"    #2 0x55d5 in main /src/binr/radare2/radare2.c:5",
+				"",
+				"0x602000000011 is located 0 bytes to the right of 1-byte region",
+				"allocated by thread T0 here:",
+				"    #0 0x7f00 in malloc",
+			},
+			errors: []MemoryError{
+				{"AddressSanitizer", "heap-buffer-overflow", []string{"r_buf_read_at", "r_bin_open", "main"}},
+			},
+		},
+		{
+			name: "leaks",
+			stderr: []string{
+				"==1234==ERROR: LeakSanitizer: detected memory leaks",
+				"",
+				"Direct leak of 16 byte(s) in 1 object(s) allocated from:",
+				"    #0 0x7f00 in malloc",
+				"    #1 0x55d3 in r_str_new /src/libr/util/str.c:3",
+			},
+			errors: []MemoryError{
+				{"LeakSanitizer", "detected memory leaks", []string{"malloc", "r_str_new"}},
+			},
+		},
+		{
+			name:   "ubsan",
+			stderr: []string{"libr/anal/op.c:42:10: runtime error: signed integer overflow: 2147483647 + 1 cannot be represented in type 'int'"},
+			errors: []MemoryError{
+				{"UndefinedBehaviorSanitizer", "signed integer overflow", []string{"libr/anal/op.c:42:10"}},
+			},
+		},
+		{
+			name: "valgrind",
+			stderr: []string{
+				"==99== Memcheck, a memory error detector",
+				"==99== Invalid read of size 4",
+				"==99==    at 0x4C2: r_buf_read (buf.c:10)",
+				"==99==    by 0x4C3: main (radare2.c:5)",
+				"==99==  Address 0x0 is not stack'd, malloc'd or (recently) free'd",
+				"==99== ",
+				"==99== 16 bytes in 1 blocks are definitely lost in loss record 1 of 1",
+				"==99==    at 0x4C4: malloc (vg_replace_malloc.c:299)",
+				"==99==    by 0x4C5: r_str_new (str.c:3)",
+			},
+			errors: []MemoryError{
+				{"valgrind", "Invalid read of size 4", []string{"r_buf_read", "main"}},
+				{"valgrind", "definitely lost", []string{"malloc", "r_str_new"}},
+			},
+		},
+		{
+			name:   "clean",
+			stderr: []string{"WARNING: invalid address", "==99== All heap blocks were freed -- no leaks are possible"},
+		},
+	}
+	for _, test := range tests {
+		errors := parseMemoryErrors(strings.Join(test.stderr, "\n") + "\n")
+		if !reflect.DeepEqual(errors, test.errors) {
+			t.Errorf("%s: got %#v, want %#v", test.name, errors, test.errors)
+		}
+	}
+}
+
+func TestMemoryErrorSignature(t *testing.T) {
+	memerr := MemoryError{"AddressSanitizer", "heap-use-after-free", []string{"a", "b", "c", "d"}}
+	if signature := memerr.Signature(); signature != "AddressSanitizer: heap-use-after-free in a < b < c" {
+		t.Errorf("got %q", signature)
+	}
+}
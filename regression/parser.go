@@ -0,0 +1,482 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package regression
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The text databases of radare2-regressions describe every test with a list of
+// KEY=value lines terminated by RUN, while the db/asm ones have a test per line.
+
+// IsAsm reports whether fpath is one of the db/asm databases.
+func IsAsm(fpath string) bool {
+	return strings.Contains("/"+filepath.ToSlash(fpath), "/asm/")
+}
+
+// Diagnostic is a problem found at a line of a database.
+type Diagnostic struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (diag Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d: %s", diag.File, diag.Line, diag.Message)
+}
+
+type parser struct {
+	scanner     *bufio.Scanner
+	fpath       string
+	source      string // fpath starting from the db directory
+	line        int
+	start       int             // first line of the current test
+	names       map[string]int  // line of each test name
+	keys        map[string]bool // keys already set on the current test
+	diagnostics []Diagnostic
+	// with lint the fixtures are looked up in root
+	lint bool
+	root string
+}
+
+func (p *parser) scan() bool {
+	if !p.scanner.Scan() {
+		return false
+	}
+	p.line++
+	return true
+}
+
+func (p *parser) warnAt(line int, format string, args ...interface{}) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{p.fpath, line, fmt.Sprintf(format, args...)})
+}
+
+func (p *parser) warn(format string, args ...interface{}) {
+	p.warnAt(p.line, format, args...)
+}
+
+// testKeys are the keys of a test, the base64 ones are the same key.
+var testKeys = regexp.MustCompile(`^(NAME|FILE|ARGS|BROKEN|TIMEOUT|CMDS|EXPECT|EXPECT_ERR)(64)?=`)
+
+// repeated reports whether the line sets a key that the current test already
+// has, which means that the RUN of the test is missing.
+func (p *parser) repeated(str string) bool {
+	match := testKeys.FindStringSubmatch(str)
+	if match == nil {
+		return false
+	}
+	if p.keys[match[1]] {
+		return true
+	}
+	p.keys[match[1]] = true
+	return false
+}
+
+func (p *parser) unknown(str string) {
+	if eq := strings.Index(str, "="); eq > 0 {
+		p.warn("unknown key %s", str[:eq])
+	} else {
+		p.warn("unexpected line %q", str)
+	}
+}
+
+func (p *parser) decode64(key, encoded string) string {
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		p.warn("invalid base64 in %s: %s", key, err.Error())
+		return ""
+	}
+	return string(decoded)
+}
+
+func (p *parser) multilinequote(instr string) string {
+	var s string = instr
+	start := p.line
+	for p.scan() {
+		str := p.scanner.Text()
+		if strings.HasPrefix(str, "'") {
+			return s
+		}
+		s += str + "\n"
+	}
+	p.warnAt(start, "unterminated quote")
+	return s
+}
+
+func (p *parser) populate(test *R2Test, str string) bool {
+	if strings.HasPrefix(str, "NAME=") {
+		test.Name = str[5:]
+		return true
+	} else if strings.HasPrefix(str, "ARGS=") {
+		test.Args = str[5:]
+		return true
+	} else if strings.HasPrefix(str, "FILE=") {
+		test.File = str[5:]
+		for strings.HasPrefix(test.File, "../") {
+			test.File = test.File[3:]
+		}
+		return true
+	} else if strings.HasPrefix(str, "BROKEN=") {
+		if s, err := strconv.Atoi(str[7:]); err == nil {
+			test.Broken = s == 1
+		} else {
+			test.Broken = false
+		}
+		return true
+	} else if strings.HasPrefix(str, "TIMEOUT=") {
+		if s, err := strconv.Atoi(str[8:]); err == nil && s > 0 {
+			test.Timeout = s
+		} else {
+			test.Timeout = 0
+		}
+		return true
+	} else if strings.HasPrefix(str, "EXPECT_ERR64=") {
+		test.ExpectedErr = p.decode64("EXPECT_ERR64", str[13:])
+		return true
+	} else if strings.HasPrefix(str, "EXPECT_ERR='") {
+		str = str[12:]
+		test.ExpectedErr = p.multilinequote(str)
+		return true
+	} else if strings.HasPrefix(str, "EXPECT_ERR=") {
+		test.ExpectedErr = str[11:]
+		return true
+	} else if strings.HasPrefix(str, "EXPECT64=") {
+		test.Expected = p.decode64("EXPECT64", str[9:])
+		return true
+	} else if strings.HasPrefix(str, "EXPECT='") {
+		str = str[8:]
+		test.Expected = p.multilinequote(str)
+		return true
+	} else if strings.HasPrefix(str, "EXPECT=") {
+		test.Expected = str[7:]
+		return true
+	} else if strings.HasPrefix(str, "CMDS64=") {
+		cmd := p.decode64("CMDS64", str[7:])
+		test.Commands = strings.Split(cmd, "\n")
+		return true
+	} else if strings.HasPrefix(str, "CMDS='") {
+		str = str[6:]
+		test.Commands = strings.Split(p.multilinequote(str), "\n")
+		return true
+	} else if strings.HasPrefix(str, "CMDS=") {
+		str = str[5:]
+		test.Commands = strings.Split(str, "\n")
+		return true
+	}
+	return false
+}
+
+// maxLineSize is the longest line accepted, which is needed by the base64
+// encoded values.
+const maxLineSize = 16 * 1024 * 1024
+
+var heredocStart = regexp.MustCompile(`^(\w+)=<<(\w+)$`)
+
+// heredoc reads the KEY=<<TERM value that ends at the TERM line: every line of
+// an expected output ends with a newline, while the last command has none.
+// It reports whether the key is known and whether TERM has been found.
+func (p *parser) heredoc(test *R2Test, key, term string) (known bool, terminated bool) {
+	var value string
+	start := p.line
+	for p.scan() {
+		str := p.scanner.Text()
+		if str == term {
+			terminated = true
+			break
+		}
+		value += str + "\n"
+	}
+	if !terminated {
+		p.warnAt(start, "unterminated heredoc, %s not found", term)
+	}
+	switch key {
+	case "CMDS":
+		test.Commands = strings.Split(strings.TrimSuffix(value, "\n"), "\n")
+	case "EXPECT":
+		test.Expected = value
+	case "EXPECT_ERR":
+		test.ExpectedErr = value
+	default:
+		return false, terminated
+	}
+	return true, terminated
+}
+
+func (p *parser) populate_asm(name string, test *R2Test, str string) bool {
+	if len(str) < 1 {
+		return false
+	}
+	args := strings.Split(name, "_")
+	re := regexp.MustCompile("\\w+|\".+\"")
+	found := re.FindAllString(str, -1)
+	if len(found) < 3 {
+		return false
+	}
+	cmds := found[0]
+	asm := found[1]
+	hex := found[2]
+	if len(asm) < 2 || asm[0] != '"' || asm[len(asm)-1] != '"' {
+		// the instruction has to be quoted
+		return false
+	}
+	var skip string = "0x0"
+	if len(found) > 3 {
+		skip = found[3]
+	}
+	if len(cmds) < 4 {
+		if len(args) == 1 {
+			test.Args = "-a " + args[0]
+		} else if len(args) == 2 {
+			test.Args = "-a " + args[0] + " -b " + args[1]
+		} else if len(args) == 3 {
+			test.Args = "-a " + args[0] + " -e asm.cpu=" + args[1] + " -b " + args[2]
+		} else {
+			return false
+		}
+		test.Broken = false
+		if skip != "0x0" {
+			test.Commands = append(test.Commands, "s "+skip)
+		}
+		for i := 0; i < len(cmds); i++ {
+			if cmds[i] == 'a' {
+				test.Commands = append(test.Commands, "pa "+asm[1:len(asm)-1])
+				test.Expected += hex + "\n"
+			} else if cmds[i] == 'd' {
+				test.Commands = append(test.Commands, "pad "+hex)
+				test.Expected += asm[1:len(asm)-1] + "\n"
+			} else if cmds[i] == 'B' {
+				test.Broken = true
+			} else if cmds[i] == 'E' {
+				test.Args += " -e cfg.bigendian=true"
+			} else {
+				p.warn("unknown asm flag %c", cmds[i])
+			}
+		}
+		test.File = "-"
+		test.Name = name + ": " + str
+		return true
+	}
+	return false
+}
+
+// sourceName returns the path of the database starting from its db
+// directory, like db/cmd/cmd_print, which is how the tests are located in
+// radare2-regressions.
+func sourceName(fpath string) string {
+	fpath = filepath.ToSlash(fpath)
+	if index := strings.LastIndex("/"+fpath, "/db/"); index >= 0 {
+		return fpath[index:]
+	}
+	return fpath
+}
+
+// newTest returns the empty test that the lines of a database fill in.
+func newTest() R2Test {
+	return R2Test{Commands: make([]string, 0)}
+}
+
+// finish checks the test that has just been read.
+func (p *parser) finish(test *R2Test) {
+	start := p.start
+	if start == 0 {
+		start = p.line
+	}
+	p.start = 0
+	test.Source = p.source
+	test.Line = start
+	test.LineEnd = p.line
+	if test.Name == "" {
+		p.warnAt(start, "test without NAME")
+	} else if line, ok := p.names[test.Name]; ok {
+		p.warnAt(start, "duplicate test name %q, first defined at line %d", test.Name, line)
+	} else {
+		p.names[test.Name] = start
+	}
+	if strings.TrimSpace(strings.Join(test.Commands, "")) == "" {
+		p.warnAt(start, "empty CMDS")
+	}
+	if fpath := fixturePath(test.File); p.lint && fpath != "" {
+		if _, err := os.Stat(filepath.Join(p.root, fpath)); os.IsNotExist(err) {
+			p.warnAt(start, "fixture %s does not exist", fpath)
+		}
+	}
+}
+
+// parse reads the tests of the database fpath from reader; the problems found
+// are collected in the Diagnostics of the result.
+func (p *parser) parse(reader io.Reader, fpath string) (*R2RegressionTest, error) {
+	var skipone bool = false
+	var special string
+	var str string
+	var regr R2RegressionTest
+	var e R2Test = newTest()
+	asm := IsAsm(fpath)
+	name := path.Base(filepath.ToSlash(fpath))
+	p.scanner = bufio.NewScanner(reader)
+	p.scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	p.fpath = fpath
+	p.source = sourceName(fpath)
+	p.names = make(map[string]int)
+	p.keys = make(map[string]bool)
+	for skipone || p.scan() {
+		str = p.scanner.Text()
+		if !asm && str != "" && str != "RUN" && p.start == 0 {
+			p.start = p.line
+		}
+		if !asm && p.repeated(str) {
+			// the test is dropped, as the ones missing RUN at the end.
+			p.warnAt(p.start, "missing RUN at the end of the test")
+			e = newTest()
+			p.start = p.line
+			p.keys = make(map[string]bool)
+			p.repeated(str)
+		}
+
+		if strings.Compare(str, "RUN") == 0 {
+			p.finish(&e)
+			regr.Tests = append(regr.Tests, e)
+			e = newTest()
+			p.keys = make(map[string]bool)
+			skipone = false
+		} else if strings.HasPrefix(str, "CMDS=<<EXPECT") {
+			special = "CMDS=" + str[13:]
+			start := p.line
+			skipone = false
+			for p.scan() {
+				str = p.scanner.Text()
+				if strings.HasPrefix(str, "EXPECT=") {
+					skipone = true
+					break
+				}
+				special += str + "\n"
+			}
+			if !skipone {
+				p.warnAt(start, "unterminated heredoc, EXPECT= not found")
+				special += "\n"
+			}
+			p.populate(&e, special[:len(special)-1])
+		} else if strings.HasPrefix(str, "EXPECT=<<RUN") {
+			special = "EXPECT=" + str[12:]
+			start := p.line
+			skipone = false
+			for p.scan() {
+				str = p.scanner.Text()
+				if strings.HasPrefix(str, "RUN") {
+					skipone = true
+					break
+				}
+				special += str + "\n"
+			}
+			if !skipone {
+				p.warnAt(start, "unterminated heredoc, RUN not found")
+				special += "\n"
+			}
+			p.populate(&e, special[:len(special)-1])
+		} else if match := heredocStart.FindStringSubmatch(str); !asm && match != nil {
+			known, terminated := p.heredoc(&e, match[1], match[2])
+			if !known {
+				p.unknown(str)
+			}
+			// as with EXPECT=<<RUN, the RUN that ends the heredoc also
+			// ends the test.
+			skipone = terminated && match[2] == "RUN"
+		} else {
+			if asm && p.populate_asm(name, &e, str) {
+				p.finish(&e)
+				regr.Tests = append(regr.Tests, e)
+				e = newTest()
+			} else if asm && str != "" {
+				p.warn("invalid asm test %q", str)
+				e = newTest()
+			} else if !asm && str != "" && !p.populate(&e, str) {
+				p.unknown(str)
+			}
+			skipone = false
+		}
+	}
+	if err := p.scanner.Err(); err != nil {
+		return nil, err
+	}
+	if p.start != 0 {
+		p.warnAt(p.start, "missing RUN at the end of the test")
+	}
+	if asm {
+		regr.Type = "asm"
+	} else {
+		regr.Type = "cmd"
+	}
+	for i := range regr.Tests {
+		regr.Tests[i].Database = fpath
+		regr.Tests[i].Index = i
+	}
+	sort.SliceStable(p.diagnostics, func(i, j int) bool {
+		return p.diagnostics[i].Line < p.diagnostics[j].Line
+	})
+	regr.Diagnostics = p.diagnostics
+	return &regr, nil
+}
+
+// Parse reads the tests of the database fpath from reader; the problems found
+// are collected in the Diagnostics of the result.
+func Parse(reader io.Reader, fpath string) (*R2RegressionTest, error) {
+	return (&parser{}).parse(reader, fpath)
+}
+
+// Lint parses the database stored in fpath and also checks that the fixtures
+// of its tests exist in the root directory.
+func Lint(fpath, root string) ([]Diagnostic, error) {
+	file, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	regr, err := (&parser{lint: true, root: root}).parse(file, fpath)
+	if err != nil {
+		return nil, err
+	}
+	return regr.Diagnostics, nil
+}
+
+// ParseFile reads the tests of the database stored in fpath.
+func ParseFile(fpath string) (*R2RegressionTest, error) {
+	file, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Parse(file, fpath)
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package regression
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	tests := []struct {
+		name        string
+		fpath       string
+		text        []string
+		tests       []string // names of the tests that are read
+		diagnostics []string // line: message
+	}{
+		{
+			name:  "valid",
+			fpath: "db/cmd/valid",
+			text:  []string{"NAME=a", "FILE=-", "CMDS=?e a", "EXPECT=a", "RUN", "", "NAME=b", "FILE=-", "CMDS=<<EXPECT", "?e b", "EXPECT=<<RUN", "b", "RUN"},
+			tests: []string{"a", "b"},
+		},
+		{
+			name:        "missing RUN before the next test",
+			fpath:       "db/cmd/missing",
+			text:        []string{"NAME=a", "FILE=-", "CMDS=?e a", "EXPECT=a", "NAME=b", "FILE=-", "CMDS=?e b", "EXPECT=b", "RUN"},
+			tests:       []string{"b"},
+			diagnostics: []string{"1: missing RUN at the end of the test"},
+		},
+		{
+			name:        "missing RUN at the end",
+			fpath:       "db/cmd/eof",
+			text:        []string{"NAME=a", "FILE=-", "CMDS=?e a", "RUN", "NAME=b", "FILE=-", "CMDS=?e b"},
+			tests:       []string{"a"},
+			diagnostics: []string{"5: missing RUN at the end of the test"},
+		},
+		{
+			name:  "heredoc ending at RUN",
+			fpath: "db/cmd/heredoc",
+			text:  []string{"NAME=c", "FILE=-", "CMDS=?e c", "EXPECT_ERR=<<RUN", "c", "RUN", "NAME=d", "FILE=-", "CMDS=?e d", "RUN"},
+			tests: []string{"c", "d"},
+		},
+		{
+			name:        "unterminated heredoc",
+			fpath:       "db/cmd/unterminated",
+			text:        []string{"NAME=a", "FILE=-", "CMDS=?e a", "EXPECT=<<EOF", "a"},
+			diagnostics: []string{"1: missing RUN at the end of the test", "4: unterminated heredoc, EOF not found"},
+		},
+		{
+			name:        "unknown keys and lines",
+			fpath:       "db/cmd/unknown",
+			text:        []string{"NAME=a", "FILE=-", "CMDS=?e a", "FOO=1", "garbage", "RUN"},
+			tests:       []string{"a"},
+			diagnostics: []string{"4: unknown key FOO", "5: unexpected line \"garbage\""},
+		},
+		{
+			name:        "test checks",
+			fpath:       "db/cmd/checks",
+			text:        []string{"NAME=a", "FILE=-", "CMDS=?e a", "RUN", "NAME=a", "FILE=-", "CMDS=", "EXPECT64=!", "RUN", "FILE=-", "CMDS=?e", "RUN"},
+			tests:       []string{"a", "a", ""},
+			diagnostics: []string{"5: duplicate test name \"a\", first defined at line 1", "5: empty CMDS", "8: invalid base64 in EXPECT64: illegal base64 data at input byte 0", "10: test without NAME"},
+		},
+		{
+			name:        "asm",
+			fpath:       "db/asm/x86_64",
+			text:        []string{`a "nop" 90`, `a x 90`, `dB "jmp 0" ebfe`, `aZ "nop" 90`, `a "nop"`},
+			tests:       []string{`x86_64: a "nop" 90`, `x86_64: dB "jmp 0" ebfe`, `x86_64: aZ "nop" 90`},
+			diagnostics: []string{"2: invalid asm test \"a x 90\"", "4: unknown asm flag Z", "5: invalid asm test \"a \\\"nop\\\"\""},
+		},
+	}
+	for _, test := range tests {
+		regr, err := Parse(strings.NewReader(strings.Join(test.text, "\n")+"\n"), test.fpath)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		var names []string
+		for _, parsed := range regr.Tests {
+			names = append(names, parsed.Name)
+		}
+		var diagnostics []string
+		for _, diag := range regr.Diagnostics {
+			if diag.File != test.fpath {
+				t.Errorf("%s: diagnostic of %s", test.name, diag.File)
+			}
+			diagnostics = append(diagnostics, fmt.Sprintf("%d: %s", diag.Line, diag.Message))
+		}
+		if !reflect.DeepEqual(names, test.tests) {
+			t.Errorf("%s: got the tests %q, want %q", test.name, names, test.tests)
+		}
+		if !reflect.DeepEqual(diagnostics, test.diagnostics) {
+			t.Errorf("%s: got the diagnostics %q, want %q", test.name, diagnostics, test.diagnostics)
+		}
+	}
+}
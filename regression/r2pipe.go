@@ -0,0 +1,289 @@
+// radare - LGPL - Copyright 2015 - nibble
+
+package regression
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Pipe represents a communication interface with r2 that will be used to
+// execute commands and obtain their results.
+type Pipe struct {
+	File   string
+	r2cmd  *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	Core   *struct{}
+	cmd    CmdDelegate
+	close  CloseDelegate
+	done   chan struct{}
+	stderr *stderrBuffer
+	last   string
+	closed bool
+}
+
+// An ExitError is returned by NewPipeContext when r2 exits before being
+// ready to receive commands.
+type ExitError struct {
+	Err    error
+	State  *os.ProcessState
+	Killed bool
+	Stderr string
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+// stderrBuffer keeps the last bytes written by r2 on stderr.
+type stderrBuffer struct {
+	mutex sync.Mutex
+	data  []byte
+	limit int
+}
+
+func (buffer *stderrBuffer) Write(p []byte) (int, error) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	buffer.data = append(buffer.data, p...)
+	if len(buffer.data) > buffer.limit {
+		buffer.data = append([]byte(nil), buffer.data[len(buffer.data)-buffer.limit:]...)
+	}
+	return len(p), nil
+}
+
+func (buffer *stderrBuffer) Reset() {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	buffer.data = nil
+}
+
+func (buffer *stderrBuffer) String() string {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	return string(buffer.data)
+}
+
+type CmdDelegate func(*Pipe, string) (string, error)
+type CloseDelegate func(*Pipe) error
+
+// NewPipe returns a new r2 pipe and initializes an r2 core that will try to
+// load the provided file or URI. If file is an empty string, the env vars
+// R2PIPE_{IN,OUT} will be used as file descriptors for input and output, this
+// is the case when r2pipe is called within r2.
+func NewPipe(args ...string) (*Pipe, error) {
+	return newPipeCmd(context.Background(), "radare2", args...)
+}
+
+// NewPipeContext acts like NewPipe but spawns the given r2 executable, which
+// is killed as soon as the provided context is done.
+func NewPipeContext(ctx context.Context, r2bin string, args ...string) (*Pipe, error) {
+	return newPipeCmd(ctx, r2bin, args...)
+}
+
+func newPipeFd() (*Pipe, error) {
+	r2pipeIn := os.Getenv("R2PIPE_IN")
+	r2pipeOut := os.Getenv("R2PIPE_OUT")
+	if r2pipeIn == "" || r2pipeOut == "" {
+		return nil, errors.New("missing R2PIPE_{IN,OUT} vars")
+	}
+	r2pipeInFd, err := strconv.Atoi(r2pipeIn)
+	if err != nil {
+		return nil, err
+	}
+	r2pipeOutFd, err := strconv.Atoi(r2pipeOut)
+	if err != nil {
+		return nil, err
+	}
+	stdout := os.NewFile(uintptr(r2pipeInFd), "R2PIPE_IN")
+	stdin := os.NewFile(uintptr(r2pipeOutFd), "R2PIPE_OUT")
+
+	r2p := &Pipe{
+		File:   "",
+		r2cmd:  nil,
+		stdin:  stdin,
+		stdout: stdout,
+	}
+	return r2p, nil
+}
+
+func newPipeCmd(ctx context.Context, r2bin string, args ...string) (*Pipe, error) {
+	file := args[len(args)-1]
+	args[len(args)-1] = "-q0"
+	args = append(args, file)
+	r2cmd := exec.CommandContext(ctx, r2bin, args...)
+	stderr := &stderrBuffer{limit: 1 << 20}
+	r2cmd.Stderr = stderr
+	// children of r2 may keep stderr open after it has been killed.
+	r2cmd.WaitDelay = time.Second
+	stdin, err := r2cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := r2cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := r2cmd.Start(); err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	if ctx.Done() != nil {
+		// killing r2 is not enough when one of its children still holds
+		// stdout, so the pipe is closed to unblock any pending read.
+		go func() {
+			select {
+			case <-ctx.Done():
+				stdout.Close()
+			case <-done:
+			}
+		}()
+	}
+	// Read initial data
+	if _, err := bufio.NewReader(stdout).ReadString('\x00'); err != nil {
+		close(done)
+		state, killed := terminate(r2cmd)
+		return nil, &ExitError{err, state, killed, stderr.String()}
+	}
+
+	r2p := &Pipe{
+		File:   file,
+		r2cmd:  r2cmd,
+		stdin:  stdin,
+		stdout: stdout,
+		done:   done,
+		stderr: stderr,
+	}
+	return r2p, nil
+}
+
+// terminate waits for r2 to exit, killing it when it does not exit by itself
+// within a second, and returns how it ended.
+func terminate(r2cmd *exec.Cmd) (*os.ProcessState, bool) {
+	waited := make(chan struct{})
+	go func() {
+		r2cmd.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		return r2cmd.ProcessState, false
+	case <-time.After(time.Second):
+	}
+	r2cmd.Process.Kill()
+	<-waited
+	return r2cmd.ProcessState, true
+}
+
+// Terminate is used after a command failed: it waits for r2 to exit (killing
+// it when it does not) and returns how it ended, with killed set when r2 had
+// to be killed.
+func (r2p *Pipe) Terminate() (state *os.ProcessState, killed bool) {
+	if r2p.r2cmd == nil {
+		return nil, false
+	}
+	return terminate(r2p.r2cmd)
+}
+
+// Stderr returns the output written by r2 on stderr (up to the last MiB)
+// since it was spawned or since the last call to ResetStderr. The output is
+// complete only after closing the pipe.
+func (r2p *Pipe) Stderr() string {
+	if r2p.stderr == nil {
+		return ""
+	}
+	return r2p.stderr.String()
+}
+
+// ResetStderr discards the output captured from stderr.
+func (r2p *Pipe) ResetStderr() {
+	if r2p.stderr != nil {
+		r2p.stderr.Reset()
+	}
+}
+
+// LastCmd returns the last command sent to r2.
+func (r2p *Pipe) LastCmd() string {
+	return r2p.last
+}
+
+// Write implements the standard Write interface: it writes data to the r2
+// pipe, blocking until r2 have consumed all the data.
+func (r2p *Pipe) Write(p []byte) (n int, err error) {
+	return r2p.stdin.Write(p)
+}
+
+// Read implements the standard Read interface: it reads data from the r2
+// pipe, blocking until the previously issued commands have finished.
+func (r2p *Pipe) Read(p []byte) (n int, err error) {
+	return r2p.stdout.Read(p)
+}
+
+// Cmd is a helper that allows to run r2 commands and receive their output.
+func (r2p *Pipe) Cmd(cmd string) (string, error) {
+	if r2p.Core != nil {
+		if r2p.cmd != nil {
+			return r2p.cmd(r2p, cmd)
+		}
+		return "", nil
+	}
+	r2p.last = cmd
+	if _, err := fmt.Fprintln(r2p, cmd); err != nil {
+		return "", err
+	}
+	buf, err := bufio.NewReader(r2p).ReadString('\x00')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(buf, "\x00"), nil
+}
+
+// Cmdj acts like Cmd but interprets the output of the command as json. It
+// returns the parsed json keys and values.
+func (r2p *Pipe) Cmdj(cmd string) (interface{}, error) {
+	if _, err := fmt.Fprintln(r2p, cmd); err != nil {
+		return nil, err
+	}
+	buf, err := bufio.NewReader(r2p).ReadBytes('\x00')
+	if err != nil {
+		return nil, err
+	}
+	buf = bytes.TrimRight(buf, "\n\x00")
+	var output interface{}
+	if err := json.Unmarshal(buf, &output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// Close shuts down r2, closing the created pipe.
+func (r2p *Pipe) Close() error {
+	if r2p.close != nil {
+		return r2p.close(r2p)
+	}
+	if r2p.File == "" || r2p.closed {
+		return nil
+	}
+	r2p.closed = true
+	defer close(r2p.done)
+	if _, err := r2p.Cmd("q!"); err != nil {
+		// r2 is not answering anymore (killed or crashed), so the
+		// process is reaped without waiting for a clean exit.
+		r2p.r2cmd.Process.Kill()
+		r2p.r2cmd.Wait()
+		return err
+	}
+	return r2p.r2cmd.Wait()
+}
@@ -0,0 +1,312 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package regression
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/pmezard/go-difflib/difflib"
+	"strings"
+	"time"
+)
+
+func diff(str1, str2 string) string {
+	return diffLabels(str1, str2, "expected", "r2pipe")
+}
+
+func diffLabels(str1, str2, label1, label2 string) string {
+	diff := difflib.UnifiedDiff{
+		A:        difflib.SplitLines(str1),
+		B:        difflib.SplitLines(str2),
+		FromFile: label1,
+		ToFile:   label2,
+		Context:  3,
+	}
+	text, _ := difflib.GetUnifiedDiffString(diff)
+	return text
+}
+
+const (
+	StatusOk      = "ok"
+	StatusFailed  = "failed"
+	StatusBroken  = "broken"
+	StatusFixed   = "fixed"
+	StatusError   = "error"
+	StatusTimeout = "timeout"
+	StatusFlaky   = "flaky"
+	StatusDiffers = "differs"
+	StatusCrash   = "crash"
+	StatusMemory  = "memory"
+	StatusSkipped = "skipped"
+)
+
+type TestResult struct {
+	Message      string
+	Output       string
+	Stderr       string
+	Success      bool
+	Error        bool
+	Timeout      bool
+	Crashed      bool
+	Skipped      bool
+	Flaky        bool
+	MemoryErrors []MemoryError
+	Compared     bool
+	Differs      bool
+	Duration     time.Duration
+	Attempts     []*TestResult
+	Test         *R2Test
+	Options      *Options
+}
+
+// Status classifies the result with one of the Status* values.
+func (result TestResult) Status() string {
+	switch {
+	case result.Skipped:
+		return StatusSkipped
+	case result.Flaky:
+		return StatusFlaky
+	case result.Timeout:
+		return StatusTimeout
+	case len(result.MemoryErrors) > 0:
+		return StatusMemory
+	case result.Crashed:
+		return StatusCrash
+	case result.Error:
+		return StatusError
+	case result.Compared && result.Differs:
+		return StatusDiffers
+	case result.Compared:
+		return StatusOk
+	case result.Success && result.Test.Broken:
+		return StatusFixed
+	case result.Success:
+		return StatusOk
+	case result.Test.Broken:
+		return StatusBroken
+	}
+	return StatusFailed
+}
+
+// Failing reports whether the status is one of the failures that break a run.
+func Failing(status string) bool {
+	return status == StatusFailed || status == StatusError || status == StatusTimeout || status == StatusDiffers ||
+		status == StatusCrash || status == StatusMemory
+}
+
+// Failed reports whether the result makes the whole run fail; the broken flag
+// is ignored when comparing two builds of radare2, and skipped tests fail only
+// with --strict-fixtures.
+func (result TestResult) Failed() bool {
+	if result.Skipped {
+		return result.Options.StrictFixtures
+	}
+	return Failing(result.Status()) && (!result.Test.Broken || result.Compared)
+}
+
+// Exec runs the test in a new radare2 process, or in the one kept by cache
+// when it is not nil.
+func (test *R2Test) Exec(ctx context.Context, options *Options, cache *R2SessionCache) *TestResult {
+	result := &TestResult{Success: true, Test: test, Options: options}
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+	if reason := test.MissingFixture(); reason != "" {
+		result.Skipped = true
+		result.Message = reason
+		return result
+	}
+	if test.ExpectedErr != "" || len(options.Wrapper) > 0 {
+		// stderr (and the reports of the wrapper) are complete only once
+		// radare2 exits, so a process that is not kept alive is needed.
+		cache = nil
+	}
+	runctx := ctx
+	deadline := test.Deadline(options)
+	if deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+	}
+	timedout := func() bool {
+		if ctx.Err() != context.DeadlineExceeded {
+			return false
+		}
+		result.Message = fmt.Sprintf("killed after %s", deadline)
+		result.Success = false
+		result.Error = true
+		result.Timeout = true
+		return true
+	}
+	var instance *Pipe
+	var err error
+	if cache != nil {
+		if deadline > 0 {
+			// the cached process lives longer than the test, so it is
+			// killed by hand when the test times out.
+			stop := make(chan struct{})
+			defer close(stop)
+			go func() {
+				select {
+				case <-ctx.Done():
+					if ctx.Err() == context.DeadlineExceeded {
+						cache.Kill()
+					}
+				case <-stop:
+				}
+			}()
+		}
+		instance, err = cache.Acquire(runctx, ctx, test)
+	} else {
+		instance, err = options.NewPipe(ctx, options.Radare2, test.PipeArgs())
+	}
+	if err != nil {
+		if timedout() {
+			return result
+		}
+		result.Message = fmt.Sprintf("Error: %s", err.Error())
+		result.Success = false
+		result.Error = true
+		if exit, ok := err.(*ExitError); ok && ctx.Err() == nil {
+			result.crashed(exit.State, exit.Killed, "")
+			result.Stderr = exit.Stderr
+			result.MemoryErrors = parseMemoryErrors(result.Stderr)
+		}
+		return result
+	}
+	if cache == nil {
+		defer instance.Close()
+	}
+	if test.Commands == nil {
+		return result
+	}
+	str, err := test.run(instance)
+	if err != nil {
+		if timedout() {
+			if cache != nil {
+				cache.Discard()
+			}
+			return result
+		}
+		result.Message = fmt.Sprintf("Error: %s", err.Error())
+		result.Success = false
+		result.Error = true
+		if ctx.Err() == nil {
+			state, killed := instance.Terminate()
+			result.crashed(state, killed, instance.LastCmd())
+		}
+		result.Stderr = instance.Stderr()
+		result.MemoryErrors = parseMemoryErrors(result.Stderr)
+		if cache != nil {
+			cache.Discard()
+		}
+		return result
+	}
+	if options.CompareWith == "" && len(str) < len(test.Expected) {
+		// simple workaround for bad endline; the outputs of two builds
+		// are compared as they are.
+		str += "\n"
+	}
+	result.Output = str
+	if cache == nil {
+		instance.Close()
+	}
+	result.Stderr = instance.Stderr()
+	result.MemoryErrors = parseMemoryErrors(result.Stderr)
+	if options.CompareWith != "" {
+		// the output is checked against the other build of radare2
+		// instead of the expectation of the test.
+		result.Compared = true
+		other, err := options.NewPipe(ctx, options.CompareWith, test.PipeArgs())
+		if err == nil {
+			var expected string
+			expected, err = test.run(other)
+			other.Close()
+			if err == nil && expected != str {
+				result.Message = diffLabels(expected, str, options.CompareWith, options.Radare2)
+				result.Success = false
+				result.Differs = true
+			}
+		}
+		if err != nil && !timedout() {
+			result.Message = fmt.Sprintf("Error: %s: %s", options.CompareWith, err.Error())
+			result.Success = false
+			result.Error = true
+		}
+		return result
+	}
+	if strings.Compare(str, test.Expected) != 0 {
+		diffs := diff(test.Expected, str)
+		result.Message = diffs
+		result.Success = false
+	}
+	if test.ExpectedErr != "" && result.Stderr != test.ExpectedErr {
+		result.Message += diffLabels(test.ExpectedErr, result.Stderr, "expected_err", "stderr")
+		result.Success = false
+	}
+	return result
+}
+
+// run sends the commands of the test to radare2 and returns their output.
+func (test *R2Test) run(instance *Pipe) (string, error) {
+	var buffer bytes.Buffer
+	for _, command := range test.Commands {
+		if command == "q" {
+			continue
+		}
+		output, err := instance.Cmd(command)
+		if err != nil {
+			return "", err
+		}
+		t := string(output)
+		if len(t) > 0 {
+			buffer.WriteString(t)
+		}
+	}
+	return buffer.String(), nil
+}
+
+// Retry executes a failing test again, up to --retries times and always in a
+// new radare2 process. When one of the attempts passes, the first result is
+// marked as flaky; all the attempts are kept in its Attempts.
+func (test *R2Test) Retry(ctx context.Context, options *Options, result *TestResult) *TestResult {
+	first := *result
+	result.Attempts = []*TestResult{&first}
+	for i := 0; i < options.Retries && ctx.Err() == nil; i++ {
+		options.Println("Retrying", test.Name)
+		attempt := test.Exec(ctx, options, nil)
+		result.Attempts = append(result.Attempts, attempt)
+		result.Duration += attempt.Duration
+		if !Failing(attempt.Status()) {
+			result.Flaky = true
+			break
+		}
+	}
+	return result
+}
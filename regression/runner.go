@@ -0,0 +1,193 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package regression
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+type R2Channel chan *R2Test
+type R2Results chan *TestResult
+
+// Options controls how the tests are executed.
+type Options struct {
+	Debug       bool
+	Sequence    bool
+	Jobs        int
+	Timeout     int
+	Reuse       bool
+	Retries     int
+	Radare2     string
+	CompareWith string
+	Wrapper     []string
+
+	StrictFixtures bool
+}
+
+// Runner executes the tests in a pool of radare2 processes; the hooks, when
+// set, are called from the goroutine that called Run.
+type Runner struct {
+	Tests   R2Channel
+	Results R2Results
+	Options *Options
+	Timings R2Timings
+
+	// OnStart receives the number of tests that are going to be executed.
+	OnStart func(total int)
+	// OnResult receives every result as soon as it is available or, with
+	// the Sequence option, in the order of the tests.
+	OnResult func(result *TestResult)
+	// OnFinish receives the results returned by Run.
+	OnFinish func(results []*TestResult)
+}
+
+// R2Routine is a long-lived worker: it executes the queued tests until the
+// queue is drained, results of tests interrupted by a cancellation of the run
+// are discarded.
+func R2Routine(ctx context.Context, runner *Runner, wg *sync.WaitGroup) {
+	defer wg.Done()
+	var cache *R2SessionCache
+	if runner.Options.Reuse {
+		cache = NewR2SessionCache(runner.Options)
+		defer cache.Discard()
+	}
+	for test := range runner.Tests {
+		runner.Options.Println("Executing", test.Name)
+		result := test.Exec(ctx, runner.Options, cache)
+		if runner.Options.Retries > 0 && result.Failed() && !result.Skipped {
+			result = test.Retry(ctx, runner.Options, result)
+		}
+		if ctx.Err() != nil {
+			continue
+		}
+		runner.Results <- result
+		runner.Options.Println("Result returned.")
+	}
+}
+
+// Run executes all the tests and returns their results in the same order of
+// the tests, and whether none of them failed; tests that were not executed
+// because the run was cancelled are left out.
+func (runner *Runner) Run(ctx context.Context, tests []R2Test) ([]*TestResult, bool) {
+	success := true
+	jobs := runner.Options.Jobs
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
+	}
+	runner.Tests = make(R2Channel)
+	runner.Results = make(R2Results, jobs)
+
+	runner.Options.Println("Preparing", len(tests), "tests..")
+	if runner.OnStart != nil {
+		runner.OnStart(len(tests))
+	}
+
+	order := make(map[*R2Test]int, len(tests))
+	for index := range tests {
+		order[&tests[index]] = index
+	}
+	// the tests are queued longest-first, so that the slowest ones do not
+	// run alone at the end.
+	queue := make([]int, len(tests))
+	for index := range queue {
+		queue[index] = index
+	}
+	if runner.Timings != nil {
+		sort.SliceStable(queue, func(i, j int) bool {
+			return runner.Timings.Slower(&tests[queue[i]], &tests[queue[j]])
+		})
+	}
+	go func() {
+		defer close(runner.Tests)
+		for _, index := range queue {
+			select {
+			case runner.Tests <- &tests[index]:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	runner.Options.Println("Poolsize:", jobs)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go R2Routine(ctx, runner, &wg)
+	}
+	go func() {
+		wg.Wait()
+		close(runner.Results)
+	}()
+
+	// in sequence mode the results are kept until all the previously loaded
+	// tests have been printed.
+	pending := make(map[int]*TestResult)
+	next := 0
+	results := make([]*TestResult, len(tests))
+	for result := range runner.Results {
+		results[order[result.Test]] = result
+		if result.Failed() {
+			success = false
+		}
+		if !runner.Options.Sequence {
+			runner.report(result)
+			continue
+		}
+		pending[order[result.Test]] = result
+		for r, ok := pending[next]; ok; r, ok = pending[next] {
+			runner.report(r)
+			delete(pending, next)
+			next++
+		}
+	}
+	executed := results[:0]
+	for _, result := range results {
+		if result != nil {
+			executed = append(executed, result)
+		}
+	}
+	if runner.OnFinish != nil {
+		runner.OnFinish(executed)
+	}
+	if ctx.Err() != nil {
+		return executed, false
+	}
+	return executed, success
+}
+
+func (runner *Runner) report(result *TestResult) {
+	if runner.OnResult != nil {
+		runner.OnResult(result)
+	}
+}
+
+func NewRunner(options *Options) *Runner {
+	return &Runner{Options: options}
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package regression
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// sessionState is used to take a fingerprint of radare2, which must be the
+// same after the reset of a session as right after spawning it.
+const sessionState = "e;s;o.;f~?;afl~?"
+
+// An R2Session is a radare2 process kept alive between the tests that share
+// the same arguments and file.
+type R2Session struct {
+	key    string
+	file   string
+	pipe   *Pipe
+	config []string
+	seek   string
+	state  string
+}
+
+func (session *R2Session) snapshot() error {
+	config, err := session.pipe.Cmd("e")
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(config, "\n") {
+		pair := strings.SplitN(line, " = ", 2)
+		// values with quotes cannot be restored, the verification of
+		// the reset will fallback to a new process if they change.
+		if len(pair) != 2 || strings.Contains(line, "\"") {
+			continue
+		}
+		session.config = append(session.config, "\"e "+pair[0]+"="+pair[1]+"\"")
+	}
+	if session.seek, err = session.pipe.Cmd("s"); err != nil {
+		return err
+	}
+	session.seek = strings.TrimSpace(session.seek)
+	session.state, err = session.pipe.Cmd(sessionState)
+	return err
+}
+
+// reset closes and reopens the file, restores the configuration and the seek
+// and then verifies that radare2 is in the same state it was after spawning.
+func (session *R2Session) reset() error {
+	uri := session.file
+	if uri == "-" {
+		uri = "malloc://512"
+	}
+	commands := []string{
+		"o--",
+		"o " + uri,
+		strings.Join(session.config, ";"),
+		"s " + session.seek,
+	}
+	for _, command := range commands {
+		if _, err := session.pipe.Cmd(command); err != nil {
+			return err
+		}
+	}
+	state, err := session.pipe.Cmd(sessionState)
+	if err != nil {
+		return err
+	}
+	if state != session.state {
+		return errors.New("the state of radare2 differs after the reset")
+	}
+	return nil
+}
+
+// An R2SessionCache keeps the radare2 process of a worker alive, so that it
+// can be reused by the next test with the same arguments and file instead of
+// spawning a new one.
+type R2SessionCache struct {
+	mutex   sync.Mutex
+	cancel  context.CancelFunc
+	session *R2Session
+	options *Options
+}
+
+func NewR2SessionCache(options *Options) *R2SessionCache {
+	return &R2SessionCache{options: options}
+}
+
+// Acquire returns the pipe of the cached session when it matches the test and
+// it can be reset, otherwise a new radare2 process is spawned, which lives as
+// long as runctx. Once the ctx of the test is done nothing is spawned, since
+// the new process would escape the deadline of the test.
+func (cache *R2SessionCache) Acquire(runctx, ctx context.Context, test *R2Test) (*Pipe, error) {
+	key := test.Args + "\x00" + test.File
+	if session := cache.session; session != nil {
+		if session.key == key {
+			err := session.reset()
+			if err == nil {
+				session.pipe.ResetStderr()
+				return session.pipe, nil
+			}
+			cache.options.Println("Respawning radare2:", err.Error())
+		}
+		cache.Discard()
+	}
+	sessionctx, cancel := context.WithCancel(runctx)
+	cache.mutex.Lock()
+	cache.cancel = cancel
+	cache.mutex.Unlock()
+	// a Kill that happened before the new cancel was stored is lost, so
+	// the ctx of the test is checked only now.
+	if err := ctx.Err(); err != nil {
+		cache.Discard()
+		return nil, err
+	}
+	pipe, err := NewPipeContext(sessionctx, cache.options.Radare2, test.PipeArgs()...)
+	if err != nil {
+		cache.Discard()
+		return nil, err
+	}
+	session := &R2Session{key: key, file: test.File, pipe: pipe}
+	if err := session.snapshot(); err != nil {
+		pipe.Close()
+		cache.Discard()
+		return nil, err
+	}
+	cache.session = session
+	return pipe, nil
+}
+
+// Kill terminates the radare2 process of the cache, even when it is still
+// being spawned; it is safe to call it from another goroutine.
+func (cache *R2SessionCache) Kill() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if cache.cancel != nil {
+		cache.cancel()
+	}
+}
+
+// Discard closes the cached session, the next test will spawn a new process.
+func (cache *R2SessionCache) Discard() {
+	if cache.session != nil {
+		cache.session.pipe.Close()
+		cache.session = nil
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if cache.cancel != nil {
+		cache.cancel()
+		cache.cancel = nil
+	}
+}
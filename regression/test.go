@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Package regression runs the tests of radare2-regressions: it loads their
+// databases, drives radare2 through r2pipe and collects the results with a
+// Runner, which notifies its progress through hooks.
+package regression
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type R2Test struct {
+	Name        string   `json:"name"`
+	File        string   `json:"file"`
+	Args        string   `json:"args"`
+	Commands    []string `json:"commands"`
+	Expected    string   `json:"expected"`
+	ExpectedErr string   `json:"expected_err,omitempty"`
+	Broken      bool     `json:"broken"`
+	Timeout     int      `json:"timeout,omitempty"`
+	Source      string   `json:"source,omitempty"`
+	Line        int      `json:"line,omitempty"`
+	LineEnd     int      `json:"line_end,omitempty"`
+	Database    string   `json:"-"`
+	Index       int      `json:"-"`
+}
+
+type R2RegressionTest struct {
+	Type        string       `json:"type"`
+	Tests       []R2Test     `json:"tests"`
+	Diagnostics []Diagnostic `json:"-"`
+}
+
+// SuiteName returns the name of the database without its extension, which is
+// used for the tests without a source.
+func SuiteName(database string) string {
+	name := path.Base(database)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// Suite names the database of the test: with the source of the test, like
+// db/cmd/cmd_print, which is the same for a text database and the JSON one
+// converted from it, or with SuiteName when the source is not known.
+func (test R2Test) Suite() string {
+	if test.Source != "" {
+		return test.Source
+	}
+	return SuiteName(test.Database)
+}
+
+// Key identifies a test across different runs, using the name of its database
+// instead of its path.
+func (test R2Test) Key() string {
+	return test.Suite() + "|" + test.Name + "|" + test.File + "|" + test.Args
+}
+
+// Matches reports whether the regex matches the name, the file or the
+// arguments of the test.
+func (test R2Test) Matches(re *regexp.Regexp) bool {
+	return re.MatchString(test.Name) || re.MatchString(test.File) || re.MatchString(test.Args)
+}
+
+// Shard returns the partition (between 1 and shards) of the test; it depends
+// only on the name of the test and of its database, so it is the same on
+// every machine.
+func (test R2Test) Shard(shards int) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(test.Suite() + "\x00" + test.Name))
+	return int(hash.Sum32()%uint32(shards)) + 1
+}
+
+// Location returns where the test is defined, like db/cmd/cmd_print:1234, or
+// an empty string when it is not known.
+func (test R2Test) Location() string {
+	if test.Source == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", test.Source, test.Line)
+}
+
+// Deadline returns how long the test is allowed to run before radare2 gets
+// killed; the TIMEOUT field of the test overrides the global --timeout.
+// A zero value means that the test never times out.
+func (test R2Test) Deadline(options *Options) time.Duration {
+	if test.Timeout > 0 {
+		return time.Duration(test.Timeout) * time.Second
+	}
+	return time.Duration(options.Timeout) * time.Second
+}
+
+// PipeArgs returns the arguments used to spawn radare2 for the test.
+func (test R2Test) PipeArgs() []string {
+	var args []string = strings.Split(test.Args, " ")
+	return append(args, test.File)
+}
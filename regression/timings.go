@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2018, Giovanni Dante Grazioli <deroad@libero.it>
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * * Redistributions of source code must retain the above copyright notice, this
+ *   list of conditions and the following disclaimer.
+ * * Redistributions in binary form must reproduce the above copyright notice,
+ *   this list of conditions and the following disclaimer in the documentation
+ *   and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+ * LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+ * CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+ * SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+ * INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+ * CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ * ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+ * POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package regression
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// R2Timings maps the key of each test to the duration in seconds of its last
+// execution.
+type R2Timings map[string]float64
+
+// LoadTimings reads the timings of the previous runs; a missing file just
+// means that nothing is known yet, while an invalid one is reported with an
+// error together with empty timings.
+func LoadTimings(fpath string) (R2Timings, error) {
+	timings := make(R2Timings)
+	raw, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return timings, nil
+		}
+		return timings, err
+	}
+	if err := json.Unmarshal(raw, &timings); err != nil {
+		return make(R2Timings), err
+	}
+	return timings, nil
+}
+
+// Slower reports whether a has to be scheduled before b: tests never executed
+// come first, then the ones that took longer.
+func (timings R2Timings) Slower(a, b *R2Test) bool {
+	da, oka := timings[a.Key()]
+	db, okb := timings[b.Key()]
+	if oka != okb {
+		return !oka
+	}
+	return da > db
+}
+
+// Update records the durations of the results, keeping the timings of the
+// tests that were not executed.
+func (timings R2Timings) Update(results []*TestResult) {
+	for _, result := range results {
+		timings[result.Test.Key()] = result.Duration.Seconds()
+	}
+}
+
+func (timings R2Timings) Save(fpath string) error {
+	bytes, err := json.MarshalIndent(timings, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fpath, bytes, 0644)
+}
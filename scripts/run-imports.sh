@@ -1,8 +1,7 @@
 #!/bin/bash
-SCRIPTDIR="$( cd "$( dirname "${BASH_SOURCE[0]}" )" && pwd )"
 CURDIR=$(pwd)
-bash "$SCRIPTDIR/import-tests.sh"
+if [ ! -d "radare2-regressions" ]; then
+	git clone --depth 2 https://github.com/radare/radare2-regressions || exit 1
+fi
 make
-find "$CURDIR/exported" -type f | while read FNAME; do
-	"$CURDIR/bin/r2r" "--wdir" "./radare2-regressions" "$FNAME"
-done
+"$CURDIR/bin/r2r" "--wdir" "./radare2-regressions" "./radare2-regressions/new/db"